@@ -0,0 +1,18 @@
+package interfaces
+
+import (
+	"time"
+
+	"github.com/verbeux-ai/whatsmiau/models"
+	"golang.org/x/net/context"
+)
+
+type DeadLetterRepository interface {
+	Create(ctx context.Context, letter *models.DeadLetter) error
+	// List returns the dead letters of an instance that failed between from and
+	// to, oldest first. Zero times leave that side of the range open.
+	List(ctx context.Context, instanceID string, from, to time.Time, limit int64) ([]models.DeadLetter, error)
+	Get(ctx context.Context, instanceID, id string) (*models.DeadLetter, error)
+	Delete(ctx context.Context, instanceID string, ids ...string) error
+	Purge(ctx context.Context, instanceID string, from, to time.Time) (int64, error)
+}
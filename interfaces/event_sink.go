@@ -0,0 +1,12 @@
+package interfaces
+
+import (
+	"golang.org/x/net/context"
+)
+
+// EventSink delivers the encoded events of an instance somewhere outside the
+// process. Event is the Wook name, e.g. messages.upsert.
+type EventSink interface {
+	Publish(ctx context.Context, instance, event string, data []byte) error
+	Close()
+}
@@ -9,5 +9,7 @@ type InstanceRepository interface {
 	Create(ctx context.Context, instance *models.Instance) error
 	List(ctx context.Context, id string) ([]models.Instance, error)
 	Update(ctx context.Context, id string, instance *models.Instance) (*models.Instance, error)
+	UpdateSettings(ctx context.Context, id string, settings *models.InstanceSettings) (*models.Instance, error)
+	UpdateSubscriptions(ctx context.Context, id string, update func(instance *models.Instance) ([]models.WebhookSubscription, error)) (*models.Instance, error)
 	Delete(ctx context.Context, id string) error
 }
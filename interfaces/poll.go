@@ -0,0 +1,14 @@
+package interfaces
+
+import (
+	"github.com/verbeux-ai/whatsmiau/models"
+	"golang.org/x/net/context"
+)
+
+type PollRepository interface {
+	Save(ctx context.Context, poll *models.Poll) error
+	Get(ctx context.Context, instanceID, pollID string) (*models.Poll, error)
+	// Vote replaces the selection of the voter; an empty one removes it.
+	Vote(ctx context.Context, instanceID, pollID string, vote *models.PollVote) error
+	Votes(ctx context.Context, instanceID, pollID string) ([]models.PollVote, error)
+}
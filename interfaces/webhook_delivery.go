@@ -0,0 +1,15 @@
+package interfaces
+
+import (
+	"github.com/verbeux-ai/whatsmiau/models"
+	"golang.org/x/net/context"
+)
+
+type WebhookDeliveryRepository interface {
+	Record(ctx context.Context, delivery *models.WebhookDelivery) error
+	// List returns the recorded deliveries of an instance matching the filter,
+	// newest first.
+	List(ctx context.Context, instanceID string, filter models.WebhookDeliveryFilter) ([]models.WebhookDelivery, error)
+	Stats(ctx context.Context, instanceID string) (*models.WebhookDeliveryStats, error)
+	Reset(ctx context.Context, instanceID string) error
+}
@@ -0,0 +1,129 @@
+package amqp
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/verbeux-ai/whatsmiau/interfaces"
+	"github.com/verbeux-ai/whatsmiau/lib/sinks"
+	"golang.org/x/net/context"
+)
+
+var _ interfaces.EventSink = (*Amqp)(nil)
+
+const DefaultExchange = "whatsmiau"
+
+var ErrNotConfirmed = errors.New("event was not confirmed by the broker")
+
+// Amqp publishes every event to a durable topic exchange with the routing key
+// <instance>.<event>, e.g. my-instance.messages.upsert, so queues can bind to
+// my-instance.# or *.messages.upsert. Publishes wait for the broker confirm.
+type Amqp struct {
+	url      string
+	exchange string
+
+	mu      sync.Mutex
+	conn    *amqp.Connection
+	channel *amqp.Channel
+}
+
+func New(url, exchange string) (*Amqp, error) {
+	if exchange == "" {
+		exchange = DefaultExchange
+	}
+
+	s := &Amqp{
+		url:      url,
+		exchange: exchange,
+	}
+
+	if _, err := s.getChannel(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *Amqp) RoutingKey(instance, event string) string {
+	return sinks.Key(instance, event)
+}
+
+func (s *Amqp) Publish(ctx context.Context, instance, event string, data []byte) error {
+	channel, err := s.getChannel()
+	if err != nil {
+		return err
+	}
+
+	confirm, err := channel.PublishWithDeferredConfirmWithContext(ctx, s.exchange, s.RoutingKey(instance, event), false, false, amqp.Publishing{
+		ContentType:  "application/json",
+		DeliveryMode: amqp.Persistent,
+		Timestamp:    time.Now(),
+		Type:         event,
+		AppId:        "whatsmiau",
+		Body:         data,
+	})
+	if err != nil {
+		return err
+	}
+
+	ok, err := confirm.WaitContext(ctx)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotConfirmed
+	}
+
+	return nil
+}
+
+// getChannel returns the open channel, dialing again when the connection or
+// channel was closed (amqp091 does not reconnect by itself).
+func (s *Amqp) getChannel() (*amqp.Channel, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.channel != nil && !s.channel.IsClosed() {
+		return s.channel, nil
+	}
+
+	if s.conn == nil || s.conn.IsClosed() {
+		conn, err := amqp.Dial(s.url)
+		if err != nil {
+			return nil, err
+		}
+		s.conn = conn
+	}
+
+	channel, err := s.conn.Channel()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := channel.Confirm(false); err != nil {
+		channel.Close()
+		return nil, err
+	}
+
+	if err := channel.ExchangeDeclare(s.exchange, amqp.ExchangeTopic, true, false, false, false, nil); err != nil {
+		channel.Close()
+		return nil, err
+	}
+
+	s.channel = channel
+	return channel, nil
+}
+
+func (s *Amqp) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.channel != nil {
+		s.channel.Close()
+	}
+	if s.conn != nil {
+		s.conn.Close()
+	}
+}
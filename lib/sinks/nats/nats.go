@@ -0,0 +1,68 @@
+package nats
+
+import (
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/verbeux-ai/whatsmiau/interfaces"
+	"github.com/verbeux-ai/whatsmiau/lib/sinks"
+	"go.uber.org/zap"
+	"golang.org/x/net/context"
+)
+
+var _ interfaces.EventSink = (*Nats)(nil)
+
+const DefaultPrefix = "whatsmiau"
+
+// Nats publishes every event on <prefix>.<instance>.<event>, e.g.
+// whatsmiau.my-instance.messages.upsert, so consumers can subscribe to
+// whatsmiau.my-instance.> or whatsmiau.*.messages.upsert.
+type Nats struct {
+	conn   *nats.Conn
+	prefix string
+}
+
+func New(url, prefix string) (*Nats, error) {
+	if prefix == "" {
+		prefix = DefaultPrefix
+	}
+
+	conn, err := nats.Connect(url,
+		nats.Name("whatsmiau"),
+		nats.MaxReconnects(-1),
+		nats.ReconnectWait(time.Second),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			if err != nil {
+				zap.L().Warn("nats sink disconnected", zap.String("url", url), zap.Error(err))
+			}
+		}),
+		nats.ReconnectHandler(func(_ *nats.Conn) {
+			zap.L().Info("nats sink reconnected", zap.String("url", url))
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Nats{
+		conn:   conn,
+		prefix: prefix,
+	}, nil
+}
+
+func (s *Nats) Subject(instance, event string) string {
+	return s.prefix + "." + sinks.Key(instance, event)
+}
+
+// Publish hands the event to the client, which buffers it while reconnecting.
+func (s *Nats) Publish(ctx context.Context, instance, event string, data []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return s.conn.Publish(s.Subject(instance, event), data)
+}
+
+func (s *Nats) Close() {
+	s.conn.Close()
+}
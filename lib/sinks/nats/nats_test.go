@@ -0,0 +1,44 @@
+//go:build integration
+
+package nats
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+)
+
+// Runs against a local broker: docker compose --profile brokers up -d nats
+func natsURL() string {
+	if u := os.Getenv("TEST_NATS_URL"); u != "" {
+		return u
+	}
+	return nats.DefaultURL
+}
+
+func TestNatsPublish(t *testing.T) {
+	sink, err := New(natsURL(), "whatsmiau_test")
+	require.NoError(t, err)
+	defer sink.Close()
+
+	consumer, err := nats.Connect(natsURL())
+	require.NoError(t, err)
+	defer consumer.Close()
+
+	sub, err := consumer.SubscribeSync("whatsmiau_test.my_instance.messages.>")
+	require.NoError(t, err)
+	require.NoError(t, consumer.Flush())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, sink.Publish(ctx, "my.instance", "messages.upsert", []byte(`{"event":"messages.upsert"}`)))
+
+	msg, err := sub.NextMsg(5 * time.Second)
+	require.NoError(t, err)
+	require.Equal(t, "whatsmiau_test.my_instance.messages.upsert", msg.Subject)
+	require.JSONEq(t, `{"event":"messages.upsert"}`, string(msg.Data))
+}
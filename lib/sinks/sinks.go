@@ -0,0 +1,24 @@
+package sinks
+
+import (
+	"strings"
+)
+
+// Key builds the dot separated subject (NATS) or routing key (AMQP) of an
+// event, <instance>.<event>, e.g. my-instance.messages.upsert. Characters that
+// separate tokens or act as wildcards on either broker are replaced in the
+// instance id so that it always stays a single token.
+func Key(instance, event string) string {
+	return keyReplacer.Replace(instance) + "." + event
+}
+
+var keyReplacer = strings.NewReplacer(
+	".", "_",
+	"*", "_",
+	">", "_",
+	"#", "_",
+	" ", "_",
+	"\t", "_",
+	"\n", "_",
+	"\r", "_",
+)
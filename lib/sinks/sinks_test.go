@@ -0,0 +1,21 @@
+package sinks
+
+import "testing"
+
+func TestKey(t *testing.T) {
+	tests := []struct {
+		instance string
+		event    string
+		want     string
+	}{
+		{"my-instance", "messages.upsert", "my-instance.messages.upsert"},
+		{"acme.support", "connection.update", "acme_support.connection.update"},
+		{"a *b>#", "instance.create", "a__b__.instance.create"},
+	}
+
+	for _, tt := range tests {
+		if got := Key(tt.instance, tt.event); got != tt.want {
+			t.Errorf("Key(%q, %q) = %q, want %q", tt.instance, tt.event, got, tt.want)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waE2E"
 	"go.mau.fi/whatsmeow/types"
 	"go.uber.org/zap"
 	"golang.org/x/net/context"
@@ -47,6 +48,23 @@ func (s *Whatsmiau) ChatPresence(data *ChatPresenceRequest) error {
 	return client.SendChatPresence(context.TODO(), *data.RemoteJID, data.Presence, data.Media)
 }
 
+type SubscribePresenceRequest struct {
+	InstanceID string     `json:"instance_id"`
+	RemoteJID  *types.JID `json:"remote_jid"`
+}
+
+// SubscribePresence asks WhatsApp for the online and typing updates of a
+// contact, emitted as PRESENCE_UPDATE. They only arrive while the instance
+// itself is available, and the subscription lasts until it reconnects.
+func (s *Whatsmiau) SubscribePresence(ctx context.Context, data *SubscribePresenceRequest) error {
+	client, ok := s.clients.Load(data.InstanceID)
+	if !ok {
+		return whatsmeow.ErrClientIsNil
+	}
+
+	return client.SubscribePresence(ctx, s.resolveJID(ctx, client, *data.RemoteJID))
+}
+
 type NumberExistsRequest struct {
 	InstanceID string   `json:"instance_id"`
 	Numbers    []string `json:"numbers"`
@@ -153,3 +171,57 @@ func (s *Whatsmiau) DeleteMessageForEveryone(ctx context.Context, req *DeleteMes
 	_, err := client.SendMessage(ctx, chat, msg)
 	return err
 }
+
+type EditMessageType string
+
+const (
+	EditMessageText     EditMessageType = "text"
+	EditMessageImage    EditMessageType = "image"
+	EditMessageVideo    EditMessageType = "video"
+	EditMessageDocument EditMessageType = "document"
+)
+
+type EditMessageRequest struct {
+	InstanceID string          `json:"instance_id"`
+	RemoteJID  *types.JID      `json:"remote_jid"`
+	MessageID  string          `json:"message_id"`
+	Text       string          `json:"text"`
+	Type       EditMessageType `json:"type"` // kind of the original message, text by default
+}
+
+type EditMessageResponse struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// EditMessage replaces the text, or the caption of media, of a message we
+// sent. WhatsApp only accepts edits within 15 minutes of sending.
+func (s *Whatsmiau) EditMessage(ctx context.Context, req *EditMessageRequest) (*EditMessageResponse, error) {
+	client, ok := s.clients.Load(req.InstanceID)
+	if !ok {
+		return nil, whatsmeow.ErrClientIsNil
+	}
+
+	var content *waE2E.Message
+	switch req.Type {
+	case EditMessageImage:
+		content = &waE2E.Message{ImageMessage: &waE2E.ImageMessage{Caption: &req.Text}}
+	case EditMessageVideo:
+		content = &waE2E.Message{VideoMessage: &waE2E.VideoMessage{Caption: &req.Text}}
+	case EditMessageDocument:
+		content = &waE2E.Message{DocumentMessage: &waE2E.DocumentMessage{Caption: &req.Text}}
+	default:
+		content = &waE2E.Message{Conversation: &req.Text}
+	}
+
+	chat := s.resolveJID(ctx, client, *req.RemoteJID)
+	res, err := client.SendMessage(ctx, chat, client.BuildEdit(chat, types.MessageID(req.MessageID), content))
+	if err != nil {
+		return nil, err
+	}
+
+	return &EditMessageResponse{
+		ID:        res.ID,
+		CreatedAt: res.Timestamp,
+	}, nil
+}
@@ -0,0 +1,62 @@
+package whatsmiau
+
+import (
+	"time"
+
+	"github.com/verbeux-ai/whatsmiau/models"
+	"go.uber.org/zap"
+	"golang.org/x/net/context"
+)
+
+func (s *Whatsmiau) storeDeadLetter(event emitter, result emitResult) error {
+	if event.instance == "" {
+		return nil
+	}
+
+	ctx, c := context.WithTimeout(context.Background(), time.Second*5)
+	defer c()
+
+	if err := s.deadLetters.Create(ctx, &models.DeadLetter{
+		InstanceID:   event.instance,
+		Subscription: event.subscription,
+		Event:        string(event.event),
+		Url:          event.url,
+		Payload:      event.data,
+		StatusCode:   result.StatusCode,
+		Response:     result.Response,
+		Error:        result.Error,
+		Attempts:     event.attempts,
+		Batch:        event.batch,
+	}); err != nil {
+		zap.L().Error("failed to store dead letter", zap.String("instance", event.instance), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+// ReplayDeadLetters puts the given dead letters back on the emitter queue and
+// removes them from the dead letter store. It returns how many were requeued;
+// letters that fail again end up back in the store with a new id.
+func (s *Whatsmiau) ReplayDeadLetters(ctx context.Context, letters []models.DeadLetter) (int, error) {
+	replayed := 0
+	for _, letter := range letters {
+		if err := s.enqueueEmit(emitter{
+			instance:     letter.InstanceID,
+			subscription: letter.Subscription,
+			event:        Wook(letter.Event),
+			url:          letter.Url,
+			data:         letter.Payload,
+			batch:        letter.Batch,
+		}); err != nil {
+			return replayed, err
+		}
+
+		if err := s.deadLetters.Delete(ctx, letter.InstanceID, letter.ID); err != nil {
+			return replayed, err
+		}
+		replayed++
+	}
+
+	return replayed, nil
+}
@@ -0,0 +1,130 @@
+package whatsmiau
+
+import (
+	"bytes"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/verbeux-ai/whatsmiau/models"
+	"golang.org/x/net/context"
+)
+
+// defaultBatchInterval is how long a batch waits for more events when the
+// webhook does not set an interval.
+const defaultBatchInterval = time.Second
+
+// wookBatch is the event of a batch that mixes several events.
+const wookBatch Wook = "batch"
+
+// emitBatch is the events collected for one destination. Their stream entries
+// stay pending until the batch is handed on, so a crash loses nothing.
+type emitBatch struct {
+	event  emitter // first event, carries the destination
+	ids    []string
+	data   [][]byte
+	events map[Wook]bool
+	timer  *time.Timer
+}
+
+// emitBatcher collects the events of batching webhooks per destination
+// (instance, subscription and URL).
+type emitBatcher struct {
+	mu      sync.Mutex
+	batches map[string]*emitBatch
+}
+
+func newEmitBatcher() *emitBatcher {
+	return &emitBatcher{batches: make(map[string]*emitBatch)}
+}
+
+// batchEnabled is false for templated webhooks, whose deliveries are single
+// events; the API rejects the combination, see models.ErrBatchedTemplate.
+func batchEnabled(webhook *models.InstanceWebhook) bool {
+	return webhook != nil && webhook.Batch != nil && webhook.Batch.Size > 1 && !templateEnabled(webhook)
+}
+
+// add appends the event to the batch of its destination. The batch is flushed
+// by the caller once it is full, or from a timer when the interval passes.
+func (b *emitBatcher) add(id string, event emitter, batch *models.WebhookBatch, flush func(*emitBatch)) {
+	key := event.instance + "|" + event.subscription + "|" + event.url
+
+	b.mu.Lock()
+	current, ok := b.batches[key]
+	if !ok {
+		interval := defaultBatchInterval
+		if batch.Interval > 0 {
+			interval = time.Duration(batch.Interval) * time.Millisecond
+		}
+
+		current = &emitBatch{event: event, events: make(map[Wook]bool)}
+		current.timer = time.AfterFunc(interval, func() {
+			if b.take(key, current) {
+				flush(current)
+			}
+		})
+		b.batches[key] = current
+	}
+
+	current.ids = append(current.ids, id)
+	current.data = append(current.data, event.data)
+	current.events[event.event] = true
+
+	full := len(current.ids) >= batch.Size
+	if full {
+		current.timer.Stop()
+		delete(b.batches, key)
+	}
+	b.mu.Unlock()
+
+	if full {
+		flush(current)
+	}
+}
+
+// take removes the batch if it was not flushed for being full in the meantime.
+func (b *emitBatcher) take(key string, batch *emitBatch) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.batches[key] != batch {
+		return false
+	}
+
+	delete(b.batches, key)
+	return true
+}
+
+// emitter turns the batch into a single delivery with a JSON array body. Its
+// event is the one shared by every item, or "batch" when they are mixed.
+func (batch *emitBatch) emitter() emitter {
+	event := batch.event
+	event.data = append(append([]byte("["), bytes.Join(batch.data, []byte(","))...), ']')
+	event.batch = len(batch.data)
+	event.messageID = ""
+	event.attempts = 0
+	if len(batch.events) > 1 {
+		event.event = wookBatch
+	}
+
+	return event
+}
+
+// batchStreamEntry queues the event on the batch of its destination, which
+// acknowledges every stream entry in it once delivered.
+func (s *Whatsmiau) batchStreamEntry(ctx context.Context, msg redis.XMessage, event emitter, batch *models.WebhookBatch) {
+	s.emitterBatcher.add(msg.ID, event, batch, func(b *emitBatch) {
+		defer func() {
+			for _, id := range b.ids {
+				s.emitterInflight.Delete(id)
+			}
+		}()
+
+		event := b.emitter()
+		if !s.processEmit(event) && !s.emitterExhausted(ctx, event, b.ids...) {
+			return // left pending, reclaimed after EMITTER_CLAIM_IDLE
+		}
+
+		s.ackStreamEntries(ctx, b.ids...)
+	})
+}
@@ -0,0 +1,99 @@
+package whatsmiau
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/verbeux-ai/whatsmiau/env"
+	"github.com/verbeux-ai/whatsmiau/models"
+)
+
+func TestEmitBatcherFlushesWhenFull(t *testing.T) {
+	b := newEmitBatcher()
+	batch := &models.WebhookBatch{Size: 2, Interval: 60000}
+
+	var flushed []*emitBatch
+	flush := func(batch *emitBatch) { flushed = append(flushed, batch) }
+
+	event := emitter{instance: "a", url: "http://receiver", event: WookMessagesUpsert}
+	event.data = []byte(`{"n":1}`)
+	b.add("1-0", event, batch, flush)
+	if len(flushed) != 0 {
+		t.Fatal("batch flushed before being full")
+	}
+
+	event.data = []byte(`{"n":2}`)
+	event.event = WookMessagesUpdate
+	b.add("2-0", event, batch, flush)
+	if len(flushed) != 1 {
+		t.Fatalf("flushed %d batches, want 1", len(flushed))
+	}
+
+	delivery := flushed[0].emitter()
+	if delivery.batch != 2 || delivery.event != wookBatch {
+		t.Fatalf("batch delivery = %d events of %q", delivery.batch, delivery.event)
+	}
+
+	var items []map[string]int
+	if err := json.Unmarshal(delivery.data, &items); err != nil || len(items) != 2 || items[1]["n"] != 2 {
+		t.Fatalf("batch body = %s (%v)", delivery.data, err)
+	}
+}
+
+func TestEmitBatcherFlushesAfterInterval(t *testing.T) {
+	b := newEmitBatcher()
+	done := make(chan *emitBatch, 1)
+
+	b.add("1-0", emitter{instance: "a", url: "http://receiver", event: WookMessagesUpsert, data: []byte(`{}`)},
+		&models.WebhookBatch{Size: 10, Interval: 10},
+		func(batch *emitBatch) { done <- batch },
+	)
+
+	select {
+	case batch := <-done:
+		if delivery := batch.emitter(); delivery.batch != 1 || delivery.event != WookMessagesUpsert {
+			t.Fatalf("batch delivery = %d events of %q", delivery.batch, delivery.event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("batch was not flushed after its interval")
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.batches) != 0 {
+		t.Fatal("flushed batch left behind")
+	}
+}
+
+func TestCompressWebhookBody(t *testing.T) {
+	env.Env.WebhookGzipMinSize = 16
+	enabled := true
+	webhook := &models.InstanceWebhook{Gzip: &enabled}
+
+	if body, ok := compressWebhookBody([]byte(`{}`), webhook); ok || string(body) != `{}` {
+		t.Fatal("small body was compressed")
+	}
+
+	data := bytes.Repeat([]byte(`{"base64":"AAAA"}`), 10)
+	if _, ok := compressWebhookBody(data, &models.InstanceWebhook{}); ok {
+		t.Fatal("body compressed without gzip enabled")
+	}
+
+	body, ok := compressWebhookBody(data, webhook)
+	if !ok {
+		t.Fatal("body was not compressed")
+	}
+
+	r, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, _ := io.ReadAll(r)
+	if !bytes.Equal(plain, data) {
+		t.Fatal("compressed body does not round trip")
+	}
+}
@@ -0,0 +1,117 @@
+package whatsmiau
+
+import (
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/verbeux-ai/whatsmiau/env"
+	"go.uber.org/zap"
+)
+
+// circuitBreaker tracks the consecutive failures of one destination host.
+// After threshold failures it opens for cooldown, during which deliveries to
+// the host are not attempted; then a single probe is let through and its
+// outcome closes or reopens it. State is kept per process.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	host      string
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+// breakerProbeWait is how long deliveries wait while the probe of a half-open
+// breaker is in flight.
+const breakerProbeWait = time.Second
+
+func newCircuitBreaker(host string, threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{
+		host:      host,
+		threshold: threshold,
+		cooldown:  cooldown,
+	}
+}
+
+// allow reports whether a delivery may be attempted now and, if not, how long
+// to wait before trying again.
+func (b *circuitBreaker) allow(now time.Time) (time.Duration, bool) {
+	if b.threshold <= 0 {
+		return 0, true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.openUntil.IsZero() {
+		return 0, true
+	}
+
+	if now.Before(b.openUntil) {
+		return b.openUntil.Sub(now), false
+	}
+
+	if b.probing {
+		return breakerProbeWait, false
+	}
+
+	b.probing = true
+	return 0, true
+}
+
+// record feeds the outcome of an attempt; healthy means the host answered,
+// even with a non-retryable client error.
+func (b *circuitBreaker) record(healthy bool, now time.Time) {
+	if b.threshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if healthy {
+		if !b.openUntil.IsZero() {
+			zap.L().Info("webhook circuit closed", zap.String("host", b.host))
+		}
+		b.failures = 0
+		b.openUntil = time.Time{}
+		b.probing = false
+		return
+	}
+
+	b.failures++
+	if b.probing || b.failures >= b.threshold {
+		if b.openUntil.IsZero() {
+			zap.L().Warn("webhook circuit opened",
+				zap.String("host", b.host),
+				zap.Int("failures", b.failures),
+				zap.Duration("cooldown", b.cooldown),
+			)
+		}
+		b.openUntil = now.Add(b.cooldown)
+		b.probing = false
+	}
+}
+
+// release ends a probe that could not be made, without an outcome.
+func (b *circuitBreaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+}
+
+func (s *Whatsmiau) breaker(rawURL string) *circuitBreaker {
+	host := rawURL
+	if u, err := url.Parse(rawURL); err == nil && u.Host != "" {
+		host = u.Host
+	}
+
+	b, _ := s.breakers.LoadOrCompute(host, func() (*circuitBreaker, bool) {
+		return newCircuitBreaker(host, env.Env.WebhookBreakerThreshold, env.Env.WebhookBreakerCooldown), false
+	})
+
+	return b
+}
@@ -0,0 +1,61 @@
+package whatsmiau
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	b := newCircuitBreaker("example.com", 2, 30*time.Second)
+
+	b.record(false, now)
+	if _, ok := b.allow(now); !ok {
+		t.Fatal("breaker opened before reaching the threshold")
+	}
+
+	b.record(false, now)
+	wait, ok := b.allow(now.Add(10 * time.Second))
+	if ok || wait != 20*time.Second {
+		t.Fatalf("allow = (%v, %v), want open for 20s", wait, ok)
+	}
+
+	// after the cooldown a single probe goes through
+	probeAt := now.Add(31 * time.Second)
+	if _, ok := b.allow(probeAt); !ok {
+		t.Fatal("probe was not allowed after cooldown")
+	}
+	if wait, ok := b.allow(probeAt); ok || wait != breakerProbeWait {
+		t.Fatalf("second delivery during probe = (%v, %v), want wait %v", wait, ok, breakerProbeWait)
+	}
+
+	// a failed probe reopens immediately
+	b.record(false, probeAt)
+	if _, ok := b.allow(probeAt.Add(time.Second)); ok {
+		t.Fatal("breaker did not reopen after failed probe")
+	}
+
+	// a successful probe closes it
+	closeAt := probeAt.Add(31 * time.Second)
+	if _, ok := b.allow(closeAt); !ok {
+		t.Fatal("probe was not allowed after second cooldown")
+	}
+	b.record(true, closeAt)
+	for range 3 {
+		if _, ok := b.allow(closeAt); !ok {
+			t.Fatal("breaker did not close after successful probe")
+		}
+	}
+}
+
+func TestCircuitBreakerDisabled(t *testing.T) {
+	b := newCircuitBreaker("example.com", 0, time.Minute)
+	now := time.Now()
+	for range 10 {
+		b.record(false, now)
+	}
+
+	if _, ok := b.allow(now); !ok {
+		t.Fatal("disabled breaker should always allow")
+	}
+}
@@ -0,0 +1,87 @@
+package whatsmiau
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/verbeux-ai/whatsmiau/env"
+	"go.uber.org/zap"
+	"golang.org/x/net/context"
+)
+
+// Deliveries that have to wait (retries, an open circuit or a rate limit) are
+// parked in a sorted set scored by their due time instead of holding a worker
+// asleep. The entry itself lives in a hash next to it, and a mover puts due
+// entries back on the emitter stream.
+const (
+	emitterDelayed     = "webhook_emitter_delayed"
+	emitterDelayedData = "webhook_emitter_delayed_data"
+	delayedBatch       = 100
+)
+
+// moveDelayedScript moves the due entries back to the stream atomically, so
+// several processes can run the mover without losing or duplicating events.
+var moveDelayedScript = redis.NewScript(`
+local ids = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, tonumber(ARGV[2]))
+for _, id in ipairs(ids) do
+	local raw = redis.call('HGET', KEYS[2], id)
+	if raw then
+		local values = cjson.decode(raw)
+		local args = {KEYS[3], 'MAXLEN', '~', ARGV[3], '*'}
+		for k, v in pairs(values) do
+			table.insert(args, k)
+			table.insert(args, v)
+		end
+		redis.call('XADD', unpack(args))
+	end
+	redis.call('ZREM', KEYS[1], id)
+	redis.call('HDEL', KEYS[2], id)
+end
+return #ids
+`)
+
+// delayEmit parks the event until delay has passed.
+func (s *Whatsmiau) delayEmit(event emitter, delay time.Duration) error {
+	data, err := json.Marshal(event.streamValues())
+	if err != nil {
+		return err
+	}
+
+	ctx, c := context.WithTimeout(context.Background(), time.Second*5)
+	defer c()
+
+	id := uuid.NewString()
+	_, err = s.redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, emitterDelayedData, id, data)
+		pipe.ZAdd(ctx, emitterDelayed, &redis.Z{
+			Score:  float64(time.Now().Add(delay).UnixMilli()),
+			Member: id,
+		})
+		return nil
+	})
+	return err
+}
+
+func (s *Whatsmiau) moveDelayedEmits(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		moved, err := moveDelayedScript.Run(ctx, s.redis,
+			[]string{emitterDelayed, emitterDelayedData, emitterStream},
+			time.Now().UnixMilli(), delayedBatch, env.Env.EmitterStreamMaxLen,
+		).Int()
+		if err != nil {
+			zap.L().Error("failed to move delayed webhook deliveries", zap.Error(err))
+		}
+
+		// keep draining while full batches come back
+		if moved == delayedBatch {
+			continue
+		}
+
+		<-ticker.C
+	}
+}
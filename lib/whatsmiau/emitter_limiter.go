@@ -0,0 +1,48 @@
+package whatsmiau
+
+import (
+	"math"
+	"time"
+
+	"github.com/verbeux-ai/whatsmiau/env"
+	"github.com/verbeux-ai/whatsmiau/models"
+	"golang.org/x/time/rate"
+)
+
+// maxLimiterSleep is the longest a worker waits for a rate limiter slot itself;
+// longer waits go through the delayed queue.
+const maxLimiterSleep = 100 * time.Millisecond
+
+// rateLimitDelay takes a slot of the URL's requests-per-second cap and returns
+// how long the delivery has to wait for it. The slot stays reserved, so the
+// caller must deliver after that delay without asking again.
+func (s *Whatsmiau) rateLimitDelay(url string, webhook *models.InstanceWebhook) time.Duration {
+	limit := env.Env.WebhookRateLimit
+	if webhook != nil && webhook.RateLimit > 0 {
+		limit = webhook.RateLimit
+	}
+	if limit <= 0 {
+		return 0
+	}
+
+	burst := env.Env.WebhookRateBurst
+	if burst <= 0 {
+		burst = int(math.Ceil(limit))
+	}
+
+	limiter, _ := s.limiters.LoadOrCompute(url, func() (*rate.Limiter, bool) {
+		return rate.NewLimiter(rate.Limit(limit), burst), false
+	})
+	if limiter.Limit() != rate.Limit(limit) || limiter.Burst() != burst {
+		limiter.SetLimit(rate.Limit(limit))
+		limiter.SetBurst(burst)
+	}
+
+	delay := limiter.Reserve().Delay()
+	if delay <= maxLimiterSleep {
+		time.Sleep(delay)
+		return 0
+	}
+
+	return delay
+}
@@ -0,0 +1,220 @@
+package whatsmiau
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/verbeux-ai/whatsmiau/env"
+	"github.com/verbeux-ai/whatsmiau/models"
+	"go.mau.fi/whatsmeow/types/events"
+	"go.uber.org/zap"
+	"golang.org/x/net/context"
+)
+
+// keyedQueue runs the functions given for the same key one after another, in
+// the order they were queued, while different keys run in parallel. A key only
+// holds a goroutine while it has work queued.
+type keyedQueue struct {
+	mu     sync.Mutex
+	queues map[string][]func()
+}
+
+func newKeyedQueue() *keyedQueue {
+	return &keyedQueue{queues: make(map[string][]func())}
+}
+
+func (q *keyedQueue) run(key string, fn func()) {
+	q.mu.Lock()
+	pending, running := q.queues[key]
+	q.queues[key] = append(pending, fn)
+	q.mu.Unlock()
+
+	if !running {
+		go q.drain(key)
+	}
+}
+
+func (q *keyedQueue) drain(key string) {
+	for {
+		q.mu.Lock()
+		pending := q.queues[key]
+		if len(pending) == 0 {
+			delete(q.queues, key)
+			q.mu.Unlock()
+			return
+		}
+		fn := pending[0]
+		q.queues[key] = pending[1:]
+		q.mu.Unlock()
+
+		fn()
+	}
+}
+
+func orderedEnabled(webhook *models.InstanceWebhook) bool {
+	if webhook.Ordered != nil {
+		return *webhook.Ordered
+	}
+
+	return env.Env.WebhookOrdered
+}
+
+// orderKey is the emitter partition of an event for a target: deliveries with
+// the same key are made in sequence. It is empty for unordered targets and
+// broker sinks, which keep the publish order themselves.
+func orderKey(target webhookTarget, instance string, chat wookChat) string {
+	if target.broker != nil || !orderedEnabled(target.webhook) {
+		return ""
+	}
+
+	key := instance + "|" + target.subscriptionID()
+	if chat.ok {
+		key += "|" + chat.jid
+	}
+
+	return key
+}
+
+// instanceOrdered reports whether any webhook of the instance delivers in
+// order, in which case its events are also handled in sequence.
+func (s *Whatsmiau) instanceOrdered(id string) bool {
+	instance := s.getInstanceCached(id)
+	if instance == nil {
+		return false
+	}
+
+	for _, target := range s.webhookTargets(instance) {
+		if target.broker == nil && orderedEnabled(target.webhook) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// eventChat is the chat of a raw whatsmeow event, empty for events that are
+// not tied to one.
+func eventChat(evt any) string {
+	switch e := evt.(type) {
+	case *events.Message:
+		return e.Info.Chat.String()
+	case *events.Receipt:
+		return e.Chat.String()
+	case *events.CallOffer:
+		return e.From.ToNonAD().String()
+	case *events.CallAccept:
+		return e.From.ToNonAD().String()
+	case *events.CallTerminate:
+		return e.From.ToNonAD().String()
+	case *events.ChatPresence:
+		return e.Chat.String()
+	case *events.GroupInfo:
+		return e.JID.String()
+	case *events.JoinedGroup:
+		return e.JID.String()
+	case *events.Presence:
+		return e.From.ToNonAD().String()
+	}
+
+	return ""
+}
+
+// Ordered deliveries are sequenced on Redis when they are queued, so a key
+// keeps its order across workers and replicas and a waiting delivery holds
+// nothing in the process. Only the head of a key is on the stream: the entries
+// queued meanwhile go to the waiting list of the key. When the head is settled
+// (delivered or dead lettered) the next waiting entry is moved to the stream
+// as the new head. A head that is retried or rate limited stays parked on the
+// delayed queue, still owning its key.
+const (
+	emitterHead    = "webhook_emitter_head:"
+	emitterWaiting = "webhook_emitter_waiting:"
+)
+
+// enqueueOrderedScript adds the entry to the stream as the head of its key
+// when the key is free, or appends it to the waiting list. The head is known
+// by its stream id, see emitter.token.
+var enqueueOrderedScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	redis.call('RPUSH', KEYS[2], ARGV[1])
+	redis.call('PEXPIRE', KEYS[2], 2 * tonumber(ARGV[2]))
+	return 0
+end
+local args = {KEYS[3], 'MAXLEN', '~', ARGV[3], '*'}
+for k, v in pairs(cjson.decode(ARGV[1])) do
+	table.insert(args, k)
+	table.insert(args, v)
+end
+redis.call('SET', KEYS[1], redis.call('XADD', unpack(args)), 'PX', ARGV[2])
+return 1
+`)
+
+// releaseOrderedScript hands the head of a key over to the next waiting entry,
+// moving it to the stream, or frees the key when nothing waits. A head settled
+// twice, e.g. redelivered after a crash, releases nothing the second time.
+var releaseOrderedScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+local raw = redis.call('LPOP', KEYS[2])
+if not raw then
+	redis.call('DEL', KEYS[1])
+	return 0
+end
+local args = {KEYS[3], 'MAXLEN', '~', ARGV[3], '*'}
+for k, v in pairs(cjson.decode(raw)) do
+	table.insert(args, k)
+	table.insert(args, v)
+end
+redis.call('SET', KEYS[1], redis.call('XADD', unpack(args)), 'PX', ARGV[2])
+return 1
+`)
+
+// orderedHeadTTL frees the key of a head that got lost, so it cannot block its
+// chat forever. It outlives WEBHOOK_MAX_DEFER, after which a head is settled.
+func orderedHeadTTL() time.Duration {
+	if env.Env.WebhookMaxDefer > 0 {
+		return env.Env.WebhookMaxDefer + time.Hour
+	}
+
+	return time.Hour * 24
+}
+
+// enqueueOrdered queues an ordered event behind the entries of its key.
+func (s *Whatsmiau) enqueueOrdered(ctx context.Context, event emitter) error {
+	data, err := json.Marshal(event.streamValues())
+	if err != nil {
+		return err
+	}
+
+	return enqueueOrderedScript.Run(ctx, s.redis,
+		[]string{emitterHead + event.key, emitterWaiting + event.key, emitterStream},
+		data, orderedHeadTTL().Milliseconds(), env.Env.EmitterStreamMaxLen,
+	).Err()
+}
+
+// releaseOrdered settles the head of the key of an ordered event.
+func (s *Whatsmiau) releaseOrdered(event emitter) {
+	ctx, c := context.WithTimeout(context.Background(), time.Second*5)
+	defer c()
+
+	err := releaseOrderedScript.Run(ctx, s.redis,
+		[]string{emitterHead + event.key, emitterWaiting + event.key, emitterStream},
+		event.token, orderedHeadTTL().Milliseconds(), env.Env.EmitterStreamMaxLen,
+	).Err()
+	if err != nil && err != redis.Nil {
+		zap.L().Error("failed to release ordered webhook delivery", zap.String("key", event.key), zap.Error(err))
+	}
+}
+
+// settleEmit releases the key of an ordered event once it was handled for
+// good, passing handled through.
+func (s *Whatsmiau) settleEmit(event emitter, handled bool) bool {
+	if handled && event.key != "" {
+		s.releaseOrdered(event)
+	}
+
+	return handled
+}
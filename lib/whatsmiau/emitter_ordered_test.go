@@ -0,0 +1,211 @@
+package whatsmiau
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/verbeux-ai/whatsmiau/env"
+	"github.com/verbeux-ai/whatsmiau/models"
+	"golang.org/x/net/context"
+)
+
+func TestKeyedQueue(t *testing.T) {
+	q := newKeyedQueue()
+
+	var mu sync.Mutex
+	var got []int
+	var wg sync.WaitGroup
+
+	// the first function of "a" blocks; "b" must not wait for it
+	release := make(chan struct{})
+	wg.Add(1)
+	q.run("a", func() {
+		defer wg.Done()
+		<-release
+		mu.Lock()
+		got = append(got, 0)
+		mu.Unlock()
+	})
+	for i := 1; i <= 5; i++ {
+		wg.Add(1)
+		q.run("a", func() {
+			defer wg.Done()
+			mu.Lock()
+			got = append(got, i)
+			mu.Unlock()
+		})
+	}
+
+	done := make(chan struct{})
+	q.run("b", func() { close(done) })
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("key b waited for key a")
+	}
+
+	close(release)
+	wg.Wait()
+
+	for i, v := range got {
+		if v != i {
+			t.Fatalf("key a ran out of order: %v", got)
+		}
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.queues) != 0 {
+		t.Fatalf("idle keys left behind: %v", q.queues)
+	}
+}
+
+func TestOrderKey(t *testing.T) {
+	ordered := true
+	webhook := &models.InstanceWebhook{Ordered: &ordered}
+	chat := wookChat{jid: "5511999999999@s.whatsapp.net", ok: true}
+
+	if key := orderKey(webhookTarget{webhook: &models.InstanceWebhook{}}, "inst", chat); key != "" {
+		t.Fatalf("unordered webhook got key %q", key)
+	}
+
+	if key := orderKey(webhookTarget{webhook: webhook}, "inst", chat); key != "inst||5511999999999@s.whatsapp.net" {
+		t.Fatalf("main webhook key = %q", key)
+	}
+
+	sub := webhookTarget{subscription: &models.WebhookSubscription{ID: "sub"}, webhook: webhook}
+	if key := orderKey(sub, "inst", wookChat{}); key != "inst|sub" {
+		t.Fatalf("event without chat key = %q", key)
+	}
+
+	broker := webhookTarget{webhook: webhook, broker: &models.InstanceSink{Type: models.EventSinkNats}}
+	if key := orderKey(broker, "inst", chat); key != "" {
+		t.Fatalf("broker sink got key %q", key)
+	}
+}
+
+func newOrderedTestMiau(t *testing.T) *Whatsmiau {
+	maxLen := env.Env.EmitterStreamMaxLen
+	env.Env.EmitterStreamMaxLen = 1000
+	t.Cleanup(func() { env.Env.EmitterStreamMaxLen = maxLen })
+
+	s := &Whatsmiau{redis: redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})}
+	if err := s.redis.XGroupCreateMkStream(context.Background(), emitterStream, emitterGroup, "0").Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	return s
+}
+
+func orderedTestEvent(key, data string) emitter {
+	return emitter{instance: "inst", event: WookMessagesUpsert, url: "http://receiver", data: []byte(data), key: key}
+}
+
+func TestOrderedDeliveryWithConcurrentWorkers(t *testing.T) {
+	s := newOrderedTestMiau(t)
+	ctx := context.Background()
+
+	const events = 5
+	for i := range events {
+		if err := s.enqueueEmit(orderedTestEvent("inst||chat", strconv.Itoa(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.enqueueEmit(orderedTestEvent("inst||other", "other")); err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var got []string
+	delivered := 0
+	deadline := time.Now().Add(5 * time.Second)
+
+	var wg sync.WaitGroup
+	for w := range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for time.Now().Before(deadline) {
+				mu.Lock()
+				done := delivered == events+1
+				mu.Unlock()
+				if done {
+					return
+				}
+
+				streams, err := s.redis.XReadGroup(ctx, &redis.XReadGroupArgs{
+					Group:    emitterGroup,
+					Consumer: fmt.Sprint("worker", w),
+					Streams:  []string{emitterStream, ">"},
+					Count:    1,
+					Block:    -1,
+				}).Result()
+				if err != nil || len(streams) == 0 || len(streams[0].Messages) == 0 {
+					time.Sleep(time.Millisecond)
+					continue
+				}
+
+				msg := streams[0].Messages[0]
+				event, _ := emitterFromStream(msg)
+				time.Sleep(5 * time.Millisecond) // the delivery
+
+				mu.Lock()
+				if event.key == "inst||chat" {
+					got = append(got, string(event.data))
+				}
+				delivered++
+				mu.Unlock()
+
+				s.settleEmit(event, true)
+				s.ackStreamEntries(ctx, msg.ID)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(got) != events {
+		t.Fatalf("expected %d deliveries of the chat, got %v", events, got)
+	}
+	for i, data := range got {
+		if data != strconv.Itoa(i) {
+			t.Fatalf("chat delivered out of order: %v", got)
+		}
+	}
+}
+
+func TestOrderedReleaseIsIdempotent(t *testing.T) {
+	s := newOrderedTestMiau(t)
+	ctx := context.Background()
+
+	for _, data := range []string{"a", "b", "c"} {
+		if err := s.enqueueEmit(orderedTestEvent("inst||chat", data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := s.redis.XLen(ctx, emitterStream).Val(); n != 1 {
+		t.Fatalf("expected only the head on the stream, got %d entries", n)
+	}
+
+	entries := s.redis.XRange(ctx, emitterStream, "-", "+").Val()
+	head, _ := emitterFromStream(entries[0])
+
+	// a head redelivered after a crash is settled twice
+	s.settleEmit(head, true)
+	s.settleEmit(head, true)
+
+	entries = s.redis.XRange(ctx, emitterStream, "-", "+").Val()
+	if len(entries) != 2 {
+		t.Fatalf("expected the head and the next entry once, got %d entries", len(entries))
+	}
+	if next, _ := emitterFromStream(entries[1]); string(next.data) != "b" {
+		t.Fatalf("expected b to be the next head, got %s", next.data)
+	}
+	if n := s.redis.LLen(ctx, emitterWaiting+"inst||chat").Val(); n != 1 {
+		t.Fatalf("expected c to keep waiting, got %d waiting", n)
+	}
+}
@@ -0,0 +1,149 @@
+package whatsmiau
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/verbeux-ai/whatsmiau/env"
+	"github.com/verbeux-ai/whatsmiau/models"
+)
+
+const (
+	defaultMaxRetries    = 2
+	defaultRetryInterval = time.Second
+	defaultRetryMax      = time.Hour
+	defaultEmitTimeout   = 10 * time.Second
+)
+
+// ErrRetryPolicyTooLong is returned for retry policies whose backoff adds up to
+// more than WEBHOOK_MAX_DEFER: their last retries would never be attempted.
+var ErrRetryPolicyTooLong = errors.New("webhook retry policy waits longer than WEBHOOK_MAX_DEFER")
+
+// retryPolicy is the WebhookRetryPolicy of a webhook with the defaults filled
+// in.
+type retryPolicy struct {
+	maxRetries int
+	backoff    models.WebhookBackoff
+	initial    time.Duration
+	max        time.Duration
+	timeout    time.Duration
+	statuses   []int // nil for 429 and 5xx
+}
+
+func webhookRetryPolicy(webhook *models.InstanceWebhook) retryPolicy {
+	p := retryPolicy{
+		maxRetries: defaultMaxRetries,
+		backoff:    models.WebhookBackoffExponential,
+		initial:    defaultRetryInterval,
+		max:        defaultRetryMax,
+		timeout:    defaultEmitTimeout,
+	}
+	if webhook == nil || webhook.Retry == nil {
+		return p
+	}
+
+	r := webhook.Retry
+	if r.MaxRetries != nil {
+		p.maxRetries = *r.MaxRetries
+	}
+	if r.Backoff != "" {
+		p.backoff = r.Backoff
+	}
+	if r.InitialInterval > 0 {
+		p.initial = time.Duration(r.InitialInterval) * time.Second
+	}
+	if r.MaxInterval > 0 {
+		p.max = time.Duration(r.MaxInterval) * time.Second
+	}
+	if r.Timeout > 0 {
+		p.timeout = time.Duration(r.Timeout) * time.Second
+	}
+	p.statuses = r.RetryStatuses
+
+	return p
+}
+
+// delay is the wait before the retry that follows the given attempt (1 for the
+// first attempt).
+func (p retryPolicy) delay(attempt int) time.Duration {
+	if p.backoff == models.WebhookBackoffFixed {
+		return min(p.initial, p.max)
+	}
+
+	d := p.max
+	if shift := attempt - 1; shift < 32 && p.initial<<shift > 0 && p.initial<<shift < p.max {
+		d = p.initial << shift
+	}
+
+	if p.backoff == models.WebhookBackoffJitter && d > 0 {
+		d = rand.N(d + 1)
+	}
+
+	return d
+}
+
+// longestWait is the sum of the delays before every retry, taking the upper
+// bound of jitter.
+func (p retryPolicy) longestWait() time.Duration {
+	if p.backoff == models.WebhookBackoffJitter {
+		p.backoff = models.WebhookBackoffExponential
+	}
+
+	var total time.Duration
+	for attempt := 1; attempt <= p.maxRetries; attempt++ {
+		total += p.delay(attempt)
+	}
+
+	return total
+}
+
+// CheckRetryPolicy rejects a retry policy that cannot run to its end within
+// WEBHOOK_MAX_DEFER. A nil policy keeps the defaults and is always accepted.
+func CheckRetryPolicy(retry *models.WebhookRetryPolicy) error {
+	maxDefer := env.Env.WebhookMaxDefer
+	if retry == nil || maxDefer <= 0 {
+		return nil
+	}
+
+	if wait := webhookRetryPolicy(&models.InstanceWebhook{Retry: retry}).longestWait(); wait > maxDefer {
+		return fmt.Errorf("%w: retries wait up to %s, over %s", ErrRetryPolicyTooLong, wait, maxDefer)
+	}
+
+	return nil
+}
+
+func (p retryPolicy) retryable(status int) bool {
+	if p.statuses != nil {
+		return slices.Contains(p.statuses, status)
+	}
+
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+// retryAfter parses the Retry-After header of 429 and 503 responses, given in
+// seconds or as an HTTP date. It is zero when absent or not applicable.
+func retryAfter(resp *http.Response, now time.Time) time.Duration {
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable {
+		return 0
+	}
+
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0)
+	}
+
+	if at, err := http.ParseTime(value); err == nil {
+		return max(at.Sub(now), 0)
+	}
+
+	return 0
+}
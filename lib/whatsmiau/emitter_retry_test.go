@@ -0,0 +1,111 @@
+package whatsmiau
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/verbeux-ai/whatsmiau/env"
+	"github.com/verbeux-ai/whatsmiau/models"
+)
+
+func TestRetryPolicyDelay(t *testing.T) {
+	retries := 0
+	p := webhookRetryPolicy(&models.InstanceWebhook{Retry: &models.WebhookRetryPolicy{
+		MaxRetries:      &retries,
+		InitialInterval: 2,
+		MaxInterval:     10,
+	}})
+	if p.maxRetries != 0 || p.timeout != defaultEmitTimeout {
+		t.Fatalf("policy = %+v", p)
+	}
+
+	for attempt, want := range map[int]time.Duration{1: 2 * time.Second, 2: 4 * time.Second, 3: 8 * time.Second, 4: 10 * time.Second, 80: 10 * time.Second} {
+		if got := p.delay(attempt); got != want {
+			t.Errorf("exponential delay(%d) = %v, want %v", attempt, got, want)
+		}
+	}
+
+	p.backoff = models.WebhookBackoffFixed
+	if got := p.delay(5); got != 2*time.Second {
+		t.Errorf("fixed delay = %v, want 2s", got)
+	}
+
+	p.backoff = models.WebhookBackoffJitter
+	for range 100 {
+		if got := p.delay(3); got < 0 || got > 8*time.Second {
+			t.Fatalf("jitter delay = %v, want within [0, 8s]", got)
+		}
+	}
+}
+
+func TestRetryPolicyRetryable(t *testing.T) {
+	p := webhookRetryPolicy(nil)
+	for status, want := range map[int]bool{429: true, 500: true, 503: true, 400: false, 404: false} {
+		if got := p.retryable(status); got != want {
+			t.Errorf("default retryable(%d) = %v, want %v", status, got, want)
+		}
+	}
+
+	p = webhookRetryPolicy(&models.InstanceWebhook{Retry: &models.WebhookRetryPolicy{RetryStatuses: []int{409}}})
+	if !p.retryable(409) || p.retryable(500) {
+		t.Error("custom retry statuses not applied")
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	response := func(status int, value string) *http.Response {
+		resp := &http.Response{StatusCode: status, Header: http.Header{}}
+		if value != "" {
+			resp.Header.Set("Retry-After", value)
+		}
+		return resp
+	}
+
+	tests := []struct {
+		resp *http.Response
+		want time.Duration
+	}{
+		{response(429, "30"), 30 * time.Second},
+		{response(503, now.Add(time.Minute).Format(http.TimeFormat)), time.Minute},
+		{response(503, now.Add(-time.Minute).Format(http.TimeFormat)), 0},
+		{response(500, "30"), 0},
+		{response(429, ""), 0},
+		{response(429, "soon"), 0},
+	}
+
+	for _, tt := range tests {
+		if got := retryAfter(tt.resp, now); got != tt.want {
+			t.Errorf("retryAfter(%d, %q) = %v, want %v", tt.resp.StatusCode, tt.resp.Header.Get("Retry-After"), got, tt.want)
+		}
+	}
+}
+
+func TestCheckRetryPolicy(t *testing.T) {
+	defer func(maxDefer time.Duration) { env.Env.WebhookMaxDefer = maxDefer }(env.Env.WebhookMaxDefer)
+	env.Env.WebhookMaxDefer = time.Hour
+
+	retries := 10
+	for name, tc := range map[string]struct {
+		retry *models.WebhookRetryPolicy
+		err   bool
+	}{
+		"defaults":       {retry: nil},
+		"empty":          {retry: &models.WebhookRetryPolicy{}},
+		"within the cap": {retry: &models.WebhookRetryPolicy{MaxRetries: &retries, InitialInterval: 5, MaxInterval: 300}},
+		"fixed past cap": {retry: &models.WebhookRetryPolicy{MaxRetries: &retries, Backoff: models.WebhookBackoffFixed, InitialInterval: 600}, err: true},
+		"jitter bound":   {retry: &models.WebhookRetryPolicy{MaxRetries: &retries, Backoff: models.WebhookBackoffJitter, InitialInterval: 60, MaxInterval: 600}, err: true},
+	} {
+		err := CheckRetryPolicy(tc.retry)
+		if tc.err != errors.Is(err, ErrRetryPolicyTooLong) || (!tc.err && err != nil) {
+			t.Errorf("%s: CheckRetryPolicy() = %v, want error %v", name, err, tc.err)
+		}
+	}
+
+	env.Env.WebhookMaxDefer = 0
+	if err := CheckRetryPolicy(&models.WebhookRetryPolicy{MaxRetries: &retries, InitialInterval: 3600, MaxInterval: 3600}); err != nil {
+		t.Errorf("CheckRetryPolicy() without a cap = %v", err)
+	}
+}
@@ -0,0 +1,355 @@
+package whatsmiau
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/verbeux-ai/whatsmiau/env"
+	"go.uber.org/zap"
+	"golang.org/x/net/context"
+)
+
+// Webhook events are persisted on a Redis Stream before delivery so that a
+// restart or crash does not lose anything still queued. Entries are only
+// acknowledged (and removed) after processEmit finishes with them, which
+// includes handing retries over to the delayed queue; anything left pending by
+// a dead consumer is reclaimed with XAUTOCLAIM once it has been idle for
+// EMITTER_CLAIM_IDLE. Live consumers keep touching the entries they hold, so
+// slow deliveries and collecting batches are never taken from them.
+const (
+	emitterStream = "webhook_emitter"
+	emitterGroup  = "whatsmiau"
+	// emitterMaxDeliveries bounds how many times an entry that could not be
+	// handed on (e.g. while the dead letter store fails) is read again
+	emitterMaxDeliveries = 5
+)
+
+type emitter struct {
+	instance     string
+	subscription string // empty for the main webhook
+	event        Wook
+	url          string
+	data         []byte
+	attempts     int       // delivery attempts made so far
+	queuedAt     time.Time // first time the event was queued, kept across retries
+	throttled    bool      // already holds a rate limiter slot
+	key          string    // delivered in sequence with the entries of the same key, empty when unordered
+	token        string    // identifies an ordered event as the head of its key
+	messageID    string    // message the event is about, for the delivery log
+	batch        int       // events in the data array of a batched delivery, 0 for a single event
+}
+
+func emitterFromStream(msg redis.XMessage) (emitter, bool) {
+	instance, _ := msg.Values["instance"].(string)
+	subscription, _ := msg.Values["subscription"].(string)
+	event, _ := msg.Values["event"].(string)
+	url, _ := msg.Values["url"].(string)
+	data, _ := msg.Values["data"].(string)
+	if url == "" || data == "" {
+		return emitter{}, false
+	}
+
+	attempts, _ := msg.Values["attempts"].(string)
+	queuedAt, _ := msg.Values["queued_at"].(string)
+	throttled, _ := msg.Values["throttled"].(string)
+	key, _ := msg.Values["key"].(string)
+	token, _ := msg.Values["token"].(string)
+	messageID, _ := msg.Values["message_id"].(string)
+	batch, _ := msg.Values["batch"].(string)
+
+	e := emitter{
+		instance:     instance,
+		subscription: subscription,
+		event:        Wook(event),
+		url:          url,
+		data:         []byte(data),
+		throttled:    throttled == "1",
+		key:          key,
+		token:        token,
+		messageID:    messageID,
+	}
+	e.attempts, _ = strconv.Atoi(attempts)
+	e.batch, _ = strconv.Atoi(batch)
+
+	// an ordered entry is known by the stream id it became the head with,
+	// which a reclaim keeps and the delayed queue carries over
+	if e.key != "" && e.token == "" {
+		e.token = msg.ID
+	}
+
+	// entries without queued_at use the time encoded in the stream id
+	if ms, err := strconv.ParseInt(queuedAt, 10, 64); err == nil {
+		e.queuedAt = time.UnixMilli(ms)
+	} else if ms, err := strconv.ParseInt(strings.Split(msg.ID, "-")[0], 10, 64); err == nil {
+		e.queuedAt = time.UnixMilli(ms)
+	} else {
+		e.queuedAt = time.Now()
+	}
+
+	return e, true
+}
+
+// streamValues is the stream entry of an event, also used by the delayed
+// queue when it moves the event back.
+func (e emitter) streamValues() map[string]any {
+	queuedAt := e.queuedAt
+	if queuedAt.IsZero() {
+		queuedAt = time.Now()
+	}
+
+	throttled := "0"
+	if e.throttled {
+		throttled = "1"
+	}
+
+	return map[string]any{
+		"instance":     e.instance,
+		"subscription": e.subscription,
+		"event":        string(e.event),
+		"url":          e.url,
+		"data":         string(e.data),
+		"attempts":     strconv.Itoa(e.attempts),
+		"queued_at":    strconv.FormatInt(queuedAt.UnixMilli(), 10),
+		"throttled":    throttled,
+		"key":          e.key,
+		"token":        e.token,
+		"message_id":   e.messageID,
+		"batch":        strconv.Itoa(e.batch),
+	}
+}
+
+func emitterConsumer() string {
+	if env.Env.EmitterConsumer != "" {
+		return env.Env.EmitterConsumer
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		return "whatsmiau"
+	}
+
+	return hostname
+}
+
+func (s *Whatsmiau) enqueueEmit(event emitter) error {
+	ctx, c := context.WithTimeout(context.Background(), time.Second*5)
+	defer c()
+
+	if event.key != "" {
+		return s.enqueueOrdered(ctx, event)
+	}
+
+	return s.redis.XAdd(ctx, &redis.XAddArgs{
+		Stream: emitterStream,
+		MaxLen: env.Env.EmitterStreamMaxLen,
+		Approx: true,
+		Values: event.streamValues(),
+	}).Err()
+}
+
+func (s *Whatsmiau) startEmitter() {
+	ctx := context.Background()
+	err := s.redis.XGroupCreateMkStream(ctx, emitterStream, emitterGroup, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		zap.L().Fatal("failed to create emitter consumer group", zap.Error(err))
+	}
+
+	workers := env.Env.EmitterWorkers
+	if workers <= 0 {
+		workers = 50
+	}
+
+	// A single reader feeds the workers so that the emitter holds one blocking
+	// redis connection instead of one per worker.
+	entries := make(chan redis.XMessage)
+	for i := 0; i < workers; i++ {
+		go func() {
+			for msg := range entries {
+				s.processStreamEntry(ctx, msg)
+			}
+		}()
+	}
+
+	dispatch := func(msg redis.XMessage) {
+		s.dispatchStreamEntry(msg, entries)
+	}
+
+	consumer := emitterConsumer()
+	go s.reclaimEmitter(ctx, consumer, int64(workers), dispatch)
+	go s.heartbeatEmitter(ctx, consumer)
+	go s.moveDelayedEmits(ctx)
+
+	for {
+		streams, err := s.redis.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    emitterGroup,
+			Consumer: consumer,
+			Streams:  []string{emitterStream, ">"},
+			Count:    int64(workers),
+			Block:    time.Second * 5,
+		}).Result()
+		if err != nil {
+			if err != redis.Nil {
+				zap.L().Error("failed to read emitter stream", zap.Error(err))
+				time.Sleep(time.Second)
+			}
+			continue
+		}
+
+		for _, stream := range streams {
+			for _, msg := range stream.Messages {
+				dispatch(msg)
+			}
+		}
+	}
+}
+
+// reclaimEmitter periodically takes over entries that were delivered to a
+// consumer but never acknowledged, which is what a crash or restart mid-delivery
+// leaves behind.
+func (s *Whatsmiau) reclaimEmitter(ctx context.Context, consumer string, count int64, dispatch func(redis.XMessage)) {
+	minIdle := emitterClaimIdle()
+	ticker := time.NewTicker(minIdle / 2)
+	defer ticker.Stop()
+
+	for {
+		start := "0-0"
+		for {
+			messages, next, err := s.redis.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+				Stream:   emitterStream,
+				Group:    emitterGroup,
+				Consumer: consumer,
+				MinIdle:  minIdle,
+				Start:    start,
+				Count:    count,
+			}).Result()
+			if err != nil {
+				zap.L().Error("failed to reclaim emitter entries", zap.Error(err))
+				break
+			}
+
+			if len(messages) > 0 {
+				zap.L().Info("resuming pending webhook deliveries", zap.Int("count", len(messages)))
+			}
+			for _, msg := range messages {
+				dispatch(msg)
+			}
+
+			if next == "0-0" || next == "" {
+				break
+			}
+			start = next
+		}
+
+		<-ticker.C
+	}
+}
+
+// heartbeatEmitter claims the entries in flight again for the consumer, which
+// resets their idle time, so a live process keeps them however long they take.
+func (s *Whatsmiau) heartbeatEmitter(ctx context.Context, consumer string) {
+	ticker := time.NewTicker(emitterClaimIdle() / 3)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		var ids []string
+		s.emitterInflight.Range(func(id string, _ struct{}) bool {
+			ids = append(ids, id)
+			return true
+		})
+		if len(ids) == 0 {
+			continue
+		}
+
+		if err := s.redis.XClaimJustID(ctx, &redis.XClaimArgs{
+			Stream:   emitterStream,
+			Group:    emitterGroup,
+			Consumer: consumer,
+			Messages: ids,
+		}).Err(); err != nil && err != redis.Nil {
+			zap.L().Error("failed to refresh emitter entries", zap.Int("count", len(ids)), zap.Error(err))
+		}
+	}
+}
+
+func emitterClaimIdle() time.Duration {
+	if env.Env.EmitterClaimIdle <= 0 {
+		return time.Minute
+	}
+
+	return env.Env.EmitterClaimIdle
+}
+
+// dispatchStreamEntry hands an entry to the workers. Entries still in flight,
+// which XAUTOCLAIM can return again while a batch collects, are skipped.
+func (s *Whatsmiau) dispatchStreamEntry(msg redis.XMessage, entries chan<- redis.XMessage) {
+	if _, loaded := s.emitterInflight.LoadOrStore(msg.ID, struct{}{}); loaded {
+		return
+	}
+
+	entries <- msg
+}
+
+func (s *Whatsmiau) processStreamEntry(ctx context.Context, msg redis.XMessage) {
+	event, ok := emitterFromStream(msg)
+	if !ok {
+		zap.L().Error("discarding malformed emitter entry", zap.String("id", msg.ID), zap.Any("values", msg.Values))
+		s.emitterInflight.Delete(msg.ID)
+		s.ackStreamEntries(ctx, msg.ID)
+		return
+	}
+
+	// single events of batching webhooks wait for the rest of their batch;
+	// ordered ones are always delivered on their own
+	if event.batch == 0 && event.key == "" {
+		if webhook, ok := s.emitterWebhook(event); ok && batchEnabled(webhook) {
+			s.batchStreamEntry(ctx, msg, event, webhook.Batch)
+			return
+		}
+	}
+
+	defer s.emitterInflight.Delete(msg.ID)
+	if !s.processEmit(event) && !s.emitterExhausted(ctx, event, msg.ID) {
+		return // left pending, reclaimed after EMITTER_CLAIM_IDLE
+	}
+
+	s.ackStreamEntries(ctx, msg.ID)
+}
+
+// emitterExhausted reports whether the entries were read emitterMaxDeliveries
+// times without being handed on, in which case the event is dropped so it is
+// not resent forever.
+func (s *Whatsmiau) emitterExhausted(ctx context.Context, event emitter, ids ...string) bool {
+	pending, err := s.redis.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: emitterStream,
+		Group:  emitterGroup,
+		Start:  ids[0],
+		End:    ids[0],
+		Count:  1,
+	}).Result()
+	if err != nil || len(pending) == 0 || pending[0].RetryCount < emitterMaxDeliveries {
+		return false
+	}
+
+	zap.L().Error("dropping webhook delivery that could not be handed on",
+		zap.String("url", event.url),
+		zap.String("instance", event.instance),
+		zap.String("event", string(event.event)),
+		zap.Int64("deliveries", pending[0].RetryCount),
+		zap.ByteString("data", event.data),
+	)
+	s.settleEmit(event, true)
+	return true
+}
+
+func (s *Whatsmiau) ackStreamEntries(ctx context.Context, ids ...string) {
+	if _, err := s.redis.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.XAck(ctx, emitterStream, emitterGroup, ids...)
+		pipe.XDel(ctx, emitterStream, ids...)
+		return nil
+	}); err != nil {
+		zap.L().Error("failed to ack emitter entries", zap.Strings("ids", ids), zap.Error(err))
+	}
+}
@@ -0,0 +1,130 @@
+package whatsmiau
+
+import (
+	"context"
+	"time"
+
+	"github.com/verbeux-ai/whatsmiau/models"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	"go.uber.org/zap"
+)
+
+// handleCallOffer applies the call settings of the instance to an incoming
+// call: RejectCall declines it and MsgCall is sent to the caller. It runs
+// whether or not CALL is subscribed and reports if the call was rejected.
+func (s *Whatsmiau) handleCallOffer(id string, instance *models.Instance, meta types.BasicCallMeta) bool {
+	if !instance.RejectCall && instance.MsgCall == "" {
+		return false
+	}
+
+	client, ok := s.clients.Load(id)
+	if !ok {
+		return false
+	}
+
+	ctx, c := context.WithTimeout(context.Background(), time.Second*10)
+	defer c()
+
+	rejected := false
+	if instance.RejectCall {
+		if err := client.RejectCall(ctx, meta.From, meta.CallID); err != nil {
+			zap.L().Error("failed to reject call", zap.String("instance", id), zap.String("call", meta.CallID), zap.Error(err))
+		} else {
+			rejected = true
+		}
+	}
+
+	if instance.MsgCall != "" {
+		caller := callCaller(meta)
+		if _, err := s.SendText(ctx, &SendText{
+			Text:       instance.MsgCall,
+			InstanceID: id,
+			RemoteJID:  &caller,
+		}); err != nil {
+			zap.L().Error("failed to send call message", zap.String("instance", id), zap.String("call", meta.CallID), zap.Error(err))
+		}
+	}
+
+	return rejected
+}
+
+func (s *Whatsmiau) handleCallEvent(id string, instance *models.Instance, evt any, eventMap map[string]bool) {
+	var (
+		meta     types.BasicCallMeta
+		status   WookCallStatus
+		isVideo  bool
+		reason   string
+		rejected bool
+	)
+
+	switch e := evt.(type) {
+	case *events.CallOffer:
+		meta, status = e.BasicCallMeta, CallStatusOffer
+		if e.Data != nil {
+			_, isVideo = e.Data.GetOptionalChildByTag("video")
+		}
+		rejected = s.handleCallOffer(id, instance, meta)
+	case *events.CallOfferNotice:
+		meta, status, isVideo = e.BasicCallMeta, CallStatusOffer, e.Media == "video"
+		rejected = s.handleCallOffer(id, instance, meta)
+	case *events.CallAccept:
+		meta, status = e.BasicCallMeta, CallStatusAccept
+	case *events.CallReject:
+		meta, status = e.BasicCallMeta, CallStatusReject
+	case *events.CallTerminate:
+		meta, status, reason = e.BasicCallMeta, CallStatusTerminate, e.Reason
+	default:
+		return
+	}
+
+	if !eventMap["CALL"] {
+		return
+	}
+
+	isGroup := !meta.GroupJID.IsEmpty()
+	if isGroup && instance.GroupsIgnore {
+		return
+	}
+
+	ctx, c := context.WithTimeout(context.Background(), time.Second*5)
+	defer c()
+
+	from, fromLid := s.GetJidLid(ctx, id, callCaller(meta))
+	data := &WookCallData{
+		Id:         meta.CallID,
+		From:       from,
+		FromLid:    fromLid,
+		ChatId:     from,
+		IsGroup:    isGroup,
+		IsVideo:    isVideo,
+		Status:     status,
+		Reason:     reason,
+		Rejected:   rejected,
+		Date:       meta.Timestamp,
+		InstanceId: instance.ID,
+	}
+	if isGroup {
+		data.GroupJid = meta.GroupJID.String()
+		data.ChatId = data.GroupJid
+	}
+
+	wookEvent := &WookEvent[WookCallData]{
+		Instance: instance.ID,
+		Data:     data,
+		DateTime: time.Now(),
+		Event:    WookCall,
+	}
+
+	zap.L().Debug("call event", zap.String("instance", id), zap.Any("data", data))
+	s.emit(wookEvent, instance)
+}
+
+// callCaller is who started the call, the same as From on 1:1 offers.
+func callCaller(meta types.BasicCallMeta) types.JID {
+	if !meta.CallCreator.IsEmpty() {
+		return meta.CallCreator.ToNonAD()
+	}
+
+	return meta.From.ToNonAD()
+}
@@ -9,7 +9,6 @@ import (
 	"net/http"
 	"os"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/emersion/go-vcard"
@@ -25,11 +24,6 @@ import (
 	"golang.org/x/net/context"
 )
 
-type emitter struct {
-	url  string
-	data any
-}
-
 func (s *Whatsmiau) getInstance(id string) *models.Instance {
 	ctx, c := context.WithTimeout(context.Background(), time.Second*5)
 	defer c()
@@ -48,22 +42,41 @@ func (s *Whatsmiau) getInstance(id string) *models.Instance {
 }
 
 func (s *Whatsmiau) getInstanceCached(id string) *models.Instance {
+	instance, err := s.lookupInstance(id)
+	if err != nil {
+		zap.L().Panic("failed to get instanceCached by instance", zap.Error(err))
+	}
+
+	return instance
+}
+
+// lookupInstance is getInstanceCached returning the Redis error instead of
+// panicking, for callers that run outside the event handlers.
+func (s *Whatsmiau) lookupInstance(id string) (*models.Instance, error) {
 	instanceCached, ok := s.instanceCache.Load(id)
 	if ok {
-		return &instanceCached
+		return &instanceCached, nil
 	}
 
 	ctx, c := context.WithTimeout(context.Background(), time.Second*5)
 	defer c()
 
+	epoch := s.instanceEpoch.Load()
 	res, err := s.repo.List(ctx, id)
 	if err != nil {
-		zap.L().Panic("failed to get instanceCached by instance", zap.Error(err))
+		return nil, err
 	}
 
 	if len(res) == 0 {
 		zap.L().Warn("no instanceCached found by instance", zap.String("instance", id))
-		return nil
+		return nil, nil
+	}
+
+	// an instance read before an invalidation must not be cached after it
+	s.instanceCacheMu.Lock()
+	defer s.instanceCacheMu.Unlock()
+	if s.instanceEpoch.Load() != epoch {
+		return &res[0], nil
 	}
 
 	s.instanceCache.Store(id, res[0])
@@ -73,173 +86,377 @@ func (s *Whatsmiau) getInstanceCached(id string) *models.Instance {
 		s.instanceCache.Delete(id)
 	}()
 
-	return &res[0]
+	return &res[0], nil
 }
 
-func (s *Whatsmiau) startEmitter() {
-	workers := env.Env.EmitterWorkers
-	if workers <= 0 {
-		workers = 50
-	}
+// invalidateInstance drops the cached instance, including a read in flight
+// that would store it again.
+func (s *Whatsmiau) invalidateInstance(id string) {
+	s.instanceCacheMu.Lock()
+	defer s.instanceCacheMu.Unlock()
 
-	var wg sync.WaitGroup
-	for i := 0; i < workers; i++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			for event := range s.emitter {
-				s.processEmit(event)
-			}
-		}()
-	}
-	wg.Wait()
+	s.instanceEpoch.Add(1)
+	s.instanceCache.Delete(id)
 }
 
-func (s *Whatsmiau) processEmit(event emitter) {
-	data, err := json.Marshal(event.data)
-	if err != nil {
-		zap.L().Error("failed to marshal event", zap.Error(err))
-		return
-	}
+// emitResult describes the outcome of a single webhook delivery attempt.
+type emitResult struct {
+	Success    bool
+	Retry      bool
+	StatusCode int
+	Response   string
+	Error      string
+	RetryAfter time.Duration // asked by the receiver with Retry-After
+	NotSent    bool          // the request never left, e.g. no auth token could be fetched
+}
 
-	const maxRetries = 2
-	backoff := time.Second
+// maxResponseSnippet bounds how much of a receiver's response body is kept
+// for logging and dead letters.
+const maxResponseSnippet = 4096
+
+// processEmit makes one delivery attempt of a queued event. Retries, open
+// circuits and rate limits park the event on the delayed queue rather than
+// holding the worker; an ordered event stays the head of its key meanwhile, so
+// the later events of the key wait behind it without holding anything either.
+// It returns false when the event could not be handed on, so the stream entry
+// stays pending and is reclaimed later.
+func (s *Whatsmiau) processEmit(event emitter) bool {
+	webhook, ok := s.emitterWebhook(event)
+	if !ok {
+		zap.L().Warn("dropping webhook event of removed subscription",
+			zap.String("instance", event.instance),
+			zap.String("subscription", event.subscription),
+		)
+		return s.settleEmit(event, true)
+	}
 
-	for attempt := 0; attempt <= maxRetries; attempt++ {
-		if attempt > 0 {
-			time.Sleep(backoff)
-			backoff *= 2
+	policy := webhookRetryPolicy(webhook)
+	if !event.throttled {
+		if delay := s.rateLimitDelay(event.url, webhook); delay > 0 {
+			event.throttled = true
+			return s.deferEmit(event, delay, emitResult{Retry: true, Error: "rate limited"})
 		}
+	}
+	event.throttled = false
 
-		success, shouldRetry := s.doEmit(data, event.url)
-		if success || !shouldRetry {
-			return
-		}
+	breaker := s.breaker(event.url)
+	if wait, ok := breaker.allow(time.Now()); !ok {
+		return s.deferEmit(event, wait, emitResult{Retry: true, Error: "circuit open for " + breaker.host})
+	}
+
+	event.attempts++
+	start := time.Now()
+	result := s.doEmit(event.data, event.url, webhookTargetKey(event.instance, event.subscription), webhook)
+	s.recordDelivery(event, result, time.Since(start))
+	if result.NotSent {
+		breaker.release() // says nothing about the receiver
+	} else {
+		// a receiver that asks to slow down is still healthy
+		breaker.record(result.Success || !result.Retry || result.RetryAfter > 0, time.Now())
+	}
+	if result.Success {
+		return s.settleEmit(event, true)
+	}
 
-		if attempt < maxRetries {
-			zap.L().Warn("webhook delivery failed, retrying",
-				zap.String("url", event.url),
-				zap.Int("attempt", attempt+1),
-				zap.Int("maxRetries", maxRetries),
-			)
+	if result.Retry && event.attempts <= policy.maxRetries {
+		zap.L().Warn("webhook delivery failed, retrying",
+			zap.String("url", event.url),
+			zap.Int("attempt", event.attempts),
+			zap.Int("maxRetries", policy.maxRetries),
+		)
+		backoff := policy.delay(event.attempts)
+		if result.RetryAfter > 0 {
+			backoff = result.RetryAfter
 		}
+		return s.deferEmit(event, backoff, result)
 	}
 
 	zap.L().Error("webhook delivery permanently failed after retries",
 		zap.String("url", event.url),
+		zap.String("instance", event.instance),
+		zap.Int("status", result.StatusCode),
 	)
+	return s.settleEmit(event, s.storeDeadLetter(event, result) == nil)
 }
 
-// doEmit performs a single webhook delivery attempt with a 10s timeout.
-// Returns (success, shouldRetry).
-func (s *Whatsmiau) doEmit(data []byte, url string) (bool, bool) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+// deferEmit parks the event for delay, or dead letters it with the last result
+// once it would stay queued longer than WEBHOOK_MAX_DEFER. Delays are never
+// shortened to fit, so a Retry-After or backoff past the cap ends the delivery.
+func (s *Whatsmiau) deferEmit(event emitter, delay time.Duration, result emitResult) bool {
+	if maxDefer := env.Env.WebhookMaxDefer; maxDefer > 0 && time.Since(event.queuedAt)+delay > maxDefer {
+		zap.L().Error("webhook delivery would wait past WEBHOOK_MAX_DEFER, dead lettering it",
+			zap.String("url", event.url),
+			zap.String("instance", event.instance),
+			zap.String("reason", result.Error),
+			zap.Duration("delay", delay),
+			zap.Duration("waited", time.Since(event.queuedAt)),
+			zap.Duration("maxDefer", maxDefer),
+		)
+		return s.settleEmit(event, s.storeDeadLetter(event, result) == nil)
+	}
+
+	if err := s.delayEmit(event, delay); err != nil {
+		zap.L().Error("failed to delay webhook delivery", zap.String("url", event.url), zap.Error(err))
+		return false
+	}
+
+	return true
+}
+
+// doEmit performs a single webhook delivery attempt. The webhook config, when
+// known, provides the headers, auth, signing secret and the retry policy that
+// sets the timeout and which responses are retried; target identifies it, see
+// webhookTargetKey.
+func (s *Whatsmiau) doEmit(data []byte, url, target string, webhook *models.InstanceWebhook) emitResult {
+	policy := webhookRetryPolicy(webhook)
+	ctx, cancel := context.WithTimeout(context.Background(), policy.timeout)
 	defer cancel()
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	body, gzipped := compressWebhookBody(data, webhook)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
 	if err != nil {
 		zap.L().Error("failed to create request", zap.Error(err))
-		return false, false
+		return emitResult{Error: err.Error()}
 	}
 
+	if err := s.setWebhookHeaders(req, target, webhook); err != nil {
+		zap.L().Error("failed to authenticate webhook", zap.Error(err), zap.String("url", url))
+		return emitResult{Retry: true, NotSent: true, Error: err.Error()}
+	}
 	req.Header.Set("Content-Type", "application/json")
+	if gzipped {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	setWebhookSignature(req.Header, webhook, data, time.Now()) // always over the uncompressed body
 	resp, err := s.httpClient.Do(req)
 	if err != nil {
 		zap.L().Error("failed to send webhook", zap.Error(err), zap.String("url", url))
-		return false, true // network error, retry
+		return emitResult{Retry: true, Error: err.Error()} // network error, retry
 	}
 	defer func() {
 		io.Copy(io.Discard, resp.Body)
 		resp.Body.Close()
 	}()
 
+	res, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseSnippet))
 	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-		return true, false
+		return emitResult{Success: true, StatusCode: resp.StatusCode, Response: string(res)}
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		s.invalidateWebhookToken(target)
 	}
 
-	if resp.StatusCode >= 500 {
-		res, _ := io.ReadAll(resp.Body)
-		zap.L().Error("webhook returned server error",
+	if policy.retryable(resp.StatusCode) {
+		zap.L().Error("webhook returned retryable error",
 			zap.Int("status", resp.StatusCode),
 			zap.String("response", string(res)),
 			zap.String("url", url),
 		)
-		return false, true // server error, retry
+		return emitResult{
+			Retry:      true,
+			StatusCode: resp.StatusCode,
+			Response:   string(res),
+			RetryAfter: retryAfter(resp, time.Now()),
+		}
 	}
 
-	// 4xx: client error, don't retry
-	res, _ := io.ReadAll(resp.Body)
-	zap.L().Error("webhook returned client error",
+	zap.L().Error("webhook returned error",
 		zap.Int("status", resp.StatusCode),
 		zap.String("response", string(res)),
 		zap.String("url", url),
 	)
-	return false, false
+	return emitResult{StatusCode: resp.StatusCode, Response: string(res)}
 }
 
-func (s *Whatsmiau) emit(body any, url string) {
-	if url == "" {
-		return
+// emitterWebhook resolves the config an entry is delivered with. It is nil when
+// the instance is gone, and not ok when the entry belongs to a subscription
+// (or global webhook) that has since been removed.
+func (s *Whatsmiau) emitterWebhook(event emitter) (*models.InstanceWebhook, bool) {
+	if event.subscription == globalWebhookID {
+		return s.globalWebhook, s.globalWebhook != nil
+	}
+
+	if event.instance == "" {
+		return nil, event.subscription == ""
+	}
+
+	instance := s.getInstanceCached(event.instance)
+	if instance == nil {
+		return nil, event.subscription == ""
 	}
-	s.emitter <- emitter{url, body}
+
+	if event.subscription == "" {
+		return &instance.Webhook, true
+	}
+
+	for i := range instance.Subscriptions {
+		if instance.Subscriptions[i].ID == event.subscription {
+			return &instance.Subscriptions[i].InstanceWebhook, true
+		}
+	}
+
+	return nil, false
 }
 
-func (s *Whatsmiau) Handle(id string) whatsmeow.EventHandler {
-	return func(evt any) {
-		s.handlerSemaphore <- struct{}{}
-		go func() {
-			defer func() { <-s.handlerSemaphore }()
-			instance := s.getInstanceCached(id)
-			if instance == nil {
-				zap.L().Warn("no instance found for event", zap.String("instance", id))
-				return
-			}
+// emit queues the event for every target of the instance that accepts it and
+// hands it to the live stream consumers.
+func (s *Whatsmiau) emit(body wookPayload, instance *models.Instance) {
+	s.emitTo(body, s.webhookTargets(instance))
+	s.stream.publish(body, false)
+}
 
-			// Handle lifecycle events regardless of webhook enabled state
-			if _, ok := evt.(*events.LoggedOut); ok {
-				s.handleLoggedOut(id)
-				return
+func (s *Whatsmiau) emitTo(body wookPayload, targets []webhookTarget) {
+	event := body.eventType()
+	chat := body.chat()
+
+	encoded := make(map[bool][]byte) // by whether inline base64 is kept
+	for _, target := range targets {
+		if !target.accepts(event, chat) {
+			continue
+		}
+
+		withBase64 := base64Enabled(target.webhook)
+		data, ok := encoded[withBase64]
+		if !ok {
+			payload := body
+			if !withBase64 {
+				payload = body.withoutBase64()
 			}
 
-			if instance.Webhook.Enabled != nil && !*instance.Webhook.Enabled {
+			var err error
+			data, err = json.Marshal(payload)
+			if err != nil {
+				zap.L().Error("failed to marshal event", zap.Error(err))
 				return
 			}
+			encoded[withBase64] = data
+		}
 
-			eventMap := make(map[string]bool)
-			for _, event := range instance.Webhook.Events {
-				eventMap[event] = true
+		if target.broker == nil && templateEnabled(target.webhook) {
+			rendered, err := s.renderWebhookTemplate(webhookTargetKey(body.instanceID(), target.subscriptionID()), *target.webhook.Template, data)
+			if err != nil {
+				zap.L().Error("failed to render webhook template",
+					zap.String("instance", body.instanceID()),
+					zap.String("event", string(event)),
+					zap.Error(err),
+				)
+				continue
 			}
 
-			switch e := evt.(type) {
-			case *events.Message:
-				s.handleMessageEvent(id, instance, e, eventMap)
-			case *events.Receipt:
-				s.handleReceiptEvent(id, instance, e, eventMap)
-			case *events.BusinessName:
-				s.handleBusinessNameEvent(id, instance, e, eventMap)
-			case *events.Contact:
-				s.handleContactEvent(id, instance, e, eventMap)
-			case *events.Picture:
-				s.handlePictureEvent(id, instance, e, eventMap)
-			case *events.HistorySync:
-				s.handleHistorySyncEvent(id, instance, e, eventMap)
-			case *events.GroupInfo:
-				s.handleGroupInfoEvent(id, instance, e, eventMap)
-			case *events.PushName:
-				s.handlePushNameEvent(id, instance, e, eventMap)
-			case *events.Connected:
-				s.handleConnectionUpdateEvent(id, instance, "open", 200, eventMap)
-			case *events.Disconnected:
-				s.handleConnectionUpdateEvent(id, instance, "close", 0, eventMap)
-			case *events.ConnectFailure:
-				s.handleConnectionUpdateEvent(id, instance, "close", int(e.Reason), eventMap)
-			default:
-				zap.L().Debug("unknown event", zap.String("type", fmt.Sprintf("%T", evt)), zap.Any("raw", evt))
-			}
+			s.publish(target, body.instanceID(), event, chat, rendered)
+			continue
+		}
+
+		s.publish(target, body.instanceID(), event, chat, data)
+	}
+}
+
+// Handle returns the event handler of an instance. Events are handled
+// concurrently, except for instances with an ordered webhook, whose events are
+// handled in sequence per chat (events without a chat share one sequence).
+func (s *Whatsmiau) Handle(id string) whatsmeow.EventHandler {
+	return func(evt any) {
+		if s.instanceOrdered(id) {
+			// queued events are bounded like running ones, blocking the client
+			// when chats fall behind; the handler slot is taken when the
+			// event's turn comes, so a busy chat does not hold the slots of the
+			// others while it waits
+			s.handlerQueued <- struct{}{}
+			s.handlerQueue.run(id+"|"+eventChat(evt), func() {
+				defer func() { <-s.handlerQueued }()
+				s.handlerSemaphore <- struct{}{}
+				defer func() { <-s.handlerSemaphore }()
+				s.handleEvent(id, evt)
+			})
+			return
+		}
+
+		s.handlerSemaphore <- struct{}{}
+		go func() {
+			defer func() { <-s.handlerSemaphore }()
+			s.handleEvent(id, evt)
 		}()
 	}
 }
 
+func (s *Whatsmiau) handleEvent(id string, evt any) {
+	instance := s.getInstanceCached(id)
+	if instance == nil {
+		zap.L().Warn("no instance found for event", zap.String("instance", id))
+		return
+	}
+
+	// Handle lifecycle events regardless of webhook enabled state
+	if _, ok := evt.(*events.LoggedOut); ok {
+		s.handleLoggedOut(id)
+		return
+	}
+
+	eventMap := s.subscribedEvents(instance)
+
+	// Calls are rejected or answered per the instance settings even when no
+	// one is subscribed to them
+	switch evt.(type) {
+	case *events.CallOffer, *events.CallOfferNotice, *events.CallAccept, *events.CallReject, *events.CallTerminate:
+		s.handleCallEvent(id, instance, evt, eventMap)
+		return
+	}
+
+	// AlwaysOnline, ReadMessages and ReadStatus apply whether or not anything
+	// is subscribed; messages are read once emitted
+	switch e := evt.(type) {
+	case *events.Connected:
+		s.handleAlwaysOnline(id, instance)
+	case *events.Message:
+		defer s.handleAutoRead(id, instance, e)
+	}
+
+	// Polls are tracked so their results can be queried, and a decrypted vote
+	// replaces the opaque pollUpdateMessage upsert
+	if e, ok := evt.(*events.Message); ok && s.handlePollEvent(id, instance, e, eventMap) {
+		return
+	}
+
+	if len(eventMap) == 0 {
+		return
+	}
+
+	switch e := evt.(type) {
+	case *events.Message:
+		s.handleMessageEvent(id, instance, e, eventMap)
+	case *events.Receipt:
+		s.handleReceiptEvent(id, instance, e, eventMap)
+	case *events.BusinessName:
+		s.handleBusinessNameEvent(id, instance, e, eventMap)
+	case *events.Contact:
+		s.handleContactEvent(id, instance, e, eventMap)
+	case *events.Picture:
+		s.handlePictureEvent(id, instance, e, eventMap)
+	case *events.HistorySync:
+		s.handleHistorySyncEvent(id, instance, e, eventMap)
+	case *events.GroupInfo:
+		s.handleGroupInfoEvent(id, instance, e, eventMap)
+		s.handleGroupChangeEvent(id, instance, e, eventMap)
+	case *events.JoinedGroup:
+		s.handleJoinedGroupEvent(id, instance, e, eventMap)
+	case *events.PushName:
+		s.handlePushNameEvent(id, instance, e, eventMap)
+	case *events.Connected:
+		s.handleConnectionUpdateEvent(id, instance, "open", 200, eventMap)
+	case *events.Disconnected:
+		s.handleConnectionUpdateEvent(id, instance, "close", 0, eventMap)
+	case *events.ConnectFailure:
+		s.handleConnectionUpdateEvent(id, instance, "close", int(e.Reason), eventMap)
+	case *events.Presence:
+		s.handlePresenceEvent(id, instance, e, eventMap)
+	case *events.ChatPresence:
+		s.handleChatPresenceEvent(id, instance, e, eventMap)
+	default:
+		zap.L().Debug("unknown event", zap.String("type", fmt.Sprintf("%T", evt)), zap.Any("raw", evt))
+	}
+}
+
 func (s *Whatsmiau) handleLoggedOut(id string) {
 	client, ok := s.clients.Load(id)
 	if ok {
@@ -250,6 +467,7 @@ func (s *Whatsmiau) handleLoggedOut(id string) {
 	}
 
 	s.clients.Delete(id)
+	s.emitLoggedOut(id, "device")
 }
 func (s *Whatsmiau) handleMessageEvent(id string, instance *models.Instance, e *events.Message, eventMap map[string]bool) {
 	if e.Message != nil {
@@ -257,6 +475,10 @@ func (s *Whatsmiau) handleMessageEvent(id string, instance *models.Instance, e *
 			s.handleMessageDeleteEvent(id, instance, e, eventMap)
 			return
 		}
+		if pm := e.Message.GetProtocolMessage(); pm != nil && pm.GetType() == waE2E.ProtocolMessage_MESSAGE_EDIT {
+			s.handleMessageEditEvent(id, instance, e, eventMap)
+			return
+		}
 	}
 
 	if !eventMap["MESSAGES_UPSERT"] {
@@ -296,7 +518,7 @@ func (s *Whatsmiau) handleMessageEvent(id string, instance *models.Instance, e *
 		zap.L().Debug("message event", zap.String("instance", id), zap.Any("data", wookMessage.Data))
 	}
 
-	s.emit(wookMessage, instance.Webhook.Url)
+	s.emit(wookMessage, instance)
 }
 
 func (s *Whatsmiau) handleMessageDeleteEvent(id string, instance *models.Instance, e *events.Message, eventMap map[string]bool) {
@@ -345,7 +567,66 @@ func (s *Whatsmiau) handleMessageDeleteEvent(id string, instance *models.Instanc
 	}
 
 	zap.L().Debug("message delete event", zap.String("instance", id), zap.Any("data", deleteData))
-	s.emit(wookEvent, instance.Webhook.Url)
+	s.emit(wookEvent, instance)
+}
+
+func (s *Whatsmiau) handleMessageEditEvent(id string, instance *models.Instance, e *events.Message, eventMap map[string]bool) {
+	if !eventMap["MESSAGES_EDITED"] {
+		return
+	}
+
+	if canIgnoreGroup(e, instance) {
+		return
+	}
+
+	if canIgnoreMessage(e) {
+		return
+	}
+
+	pm := e.Message.GetProtocolMessage()
+	pKey := pm.GetKey()
+	if pKey == nil || pm.GetEditedMessage() == nil {
+		return
+	}
+
+	ctx, c := context.WithTimeout(context.Background(), time.Second*5)
+	defer c()
+
+	remoteJid, remoteLid := s.GetJidLid(ctx, id, e.Info.Chat)
+	senderJid, _ := s.GetJidLid(ctx, id, e.Info.Sender)
+
+	messageType, raw, _ := s.parseWAMessage(pm.GetEditedMessage())
+
+	ts := e.Info.Timestamp
+	if ms := pm.GetTimestampMS(); ms > 0 {
+		ts = time.UnixMilli(ms)
+	}
+
+	editData := &WookMessageEditData{
+		Key: &WookKey{
+			RemoteJid:   remoteJid,
+			RemoteLid:   remoteLid,
+			FromMe:      pKey.GetFromMe(),
+			Id:          pKey.GetID(),
+			Participant: senderJid,
+		},
+		EditId:           e.Info.ID,
+		PushName:         e.Info.PushName,
+		Message:          raw,
+		MessageType:      messageType,
+		MessageTimestamp: int(ts.Unix()),
+		InstanceId:       instance.ID,
+	}
+
+	wookEvent := &WookEvent[WookMessageEditData]{
+		Instance: instance.ID,
+		Data:     editData,
+		DateTime: ts,
+		Event:    WookMessagesEdited,
+	}
+
+	zap.L().Debug("message edit event", zap.String("instance", id), zap.Any("data", editData))
+	s.emit(wookEvent, instance)
 }
 
 func (s *Whatsmiau) handleReceiptEvent(id string, instance *models.Instance, e *events.Receipt, eventMap map[string]bool) {
@@ -370,7 +651,7 @@ func (s *Whatsmiau) handleReceiptEvent(id string, instance *models.Instance, e *
 			Event:    WookMessagesUpdate,
 		}
 
-		s.emit(wookData, instance.Webhook.Url)
+		s.emit(wookData, instance)
 	}
 }
 
@@ -392,7 +673,7 @@ func (s *Whatsmiau) handleBusinessNameEvent(id string, instance *models.Instance
 		Event:    WookContactsUpsert,
 	}
 
-	s.emit(wookData, instance.Webhook.Url)
+	s.emit(wookData, instance)
 }
 
 func (s *Whatsmiau) handleContactEvent(id string, instance *models.Instance, e *events.Contact, eventMap map[string]bool) {
@@ -417,7 +698,7 @@ func (s *Whatsmiau) handleContactEvent(id string, instance *models.Instance, e *
 		Event:    WookContactsUpsert,
 	}
 
-	s.emit(wookData, instance.Webhook.Url)
+	s.emit(wookData, instance)
 }
 
 func (s *Whatsmiau) handlePictureEvent(id string, instance *models.Instance, e *events.Picture, eventMap map[string]bool) {
@@ -437,10 +718,14 @@ func (s *Whatsmiau) handlePictureEvent(id string, instance *models.Instance, e *
 		Event:    WookContactsUpsert,
 	}
 
-	s.emit(wookData, instance.Webhook.Url)
+	s.emit(wookData, instance)
 }
 
 func (s *Whatsmiau) handleHistorySyncEvent(id string, instance *models.Instance, e *events.HistorySync, eventMap map[string]bool) {
+	s.handleHistoryProgress(instance, e.Data, eventMap)
+	s.handleHistoryChats(id, instance, e.Data, eventMap)
+	s.handleHistoryMessages(id, instance, e.Data, eventMap)
+
 	if !eventMap["CONTACTS_UPSERT"] {
 		return
 	}
@@ -457,7 +742,7 @@ func (s *Whatsmiau) handleHistorySyncEvent(id string, instance *models.Instance,
 		Event:    WookContactsUpsert,
 	}
 
-	s.emit(wookData, instance.Webhook.Url)
+	s.emit(wookData, instance)
 }
 
 func (s *Whatsmiau) handleGroupInfoEvent(id string, instance *models.Instance, e *events.GroupInfo, eventMap map[string]bool) {
@@ -482,7 +767,7 @@ func (s *Whatsmiau) handleGroupInfoEvent(id string, instance *models.Instance, e
 		Event:    WookContactsUpsert,
 	}
 
-	s.emit(wookData, instance.Webhook.Url)
+	s.emit(wookData, instance)
 }
 
 func (s *Whatsmiau) handlePushNameEvent(id string, instance *models.Instance, e *events.PushName, eventMap map[string]bool) {
@@ -507,7 +792,7 @@ func (s *Whatsmiau) handlePushNameEvent(id string, instance *models.Instance, e
 		Event:    WookContactsUpsert,
 	}
 
-	s.emit(wookData, instance.Webhook.Url)
+	s.emit(wookData, instance)
 }
 
 func (s *Whatsmiau) handleConnectionUpdateEvent(id string, instance *models.Instance, state string, statusReason int, eventMap map[string]bool) {
@@ -536,20 +821,26 @@ func (s *Whatsmiau) handleConnectionUpdateEvent(id string, instance *models.Inst
 	}
 
 	zap.L().Debug("connection update event", zap.String("instance", id), zap.Any("data", data))
-	s.emit(wookEvent, instance.Webhook.Url)
+	s.emit(wookEvent, instance)
 }
 
 func (s *Whatsmiau) emitConnectionUpdate(id string, state string, statusReason int) {
 	instance := s.getInstanceCached(id)
-	if instance == nil || instance.Webhook.Enabled == nil || !*instance.Webhook.Enabled {
+	if instance == nil {
 		return
 	}
 
-	eventMap := make(map[string]bool)
-	for _, evt := range instance.Webhook.Events {
-		eventMap[evt] = true
+	// the updates sent outside the event handler only reach the instance
+	// webhook when it was enabled explicitly; the other targets get them as usual
+	if instance.Webhook.Enabled == nil {
+		disabled := false
+		copied := *instance
+		copied.Webhook.Enabled = &disabled
+		instance = &copied
 	}
 
+	eventMap := s.subscribedEvents(instance)
+
 	s.handleConnectionUpdateEvent(id, instance, state, statusReason, eventMap)
 }
 
@@ -923,32 +1214,7 @@ func (s *Whatsmiau) convertEventMessage(id string, instance *models.Instance, ev
 	messageType, raw, ci := s.parseWAMessage(m)
 
 	// Upload media (URL / Base64) when needed
-	switch messageType {
-	case "imageMessage":
-		if img := m.GetImageMessage(); img != nil {
-			raw.MediaURL, raw.Base64 = s.uploadMessageFile(ctx, instance, client, img, img.GetMimetype(), "")
-		}
-	case "audioMessage":
-		if aud := m.GetAudioMessage(); aud != nil {
-			raw.MediaURL, raw.Base64 = s.uploadMessageFile(ctx, instance, client, aud, aud.GetMimetype(), "")
-		}
-	case "documentMessage":
-		if doc := m.GetDocumentMessage(); doc != nil {
-			raw.MediaURL, raw.Base64 = s.uploadMessageFile(ctx, instance, client, doc, doc.GetMimetype(), doc.GetFileName())
-		}
-	case "videoMessage":
-		if vid := m.GetVideoMessage(); vid != nil {
-			raw.MediaURL, raw.Base64 = s.uploadMessageFile(ctx, instance, client, vid, vid.GetMimetype(), "")
-		}
-	case "stickerMessage":
-		if st := m.GetStickerMessage(); st != nil {
-			raw.MediaURL, raw.Base64 = s.uploadMessageFile(ctx, instance, client, st, st.GetMimetype(), "")
-		}
-	case "ptvMessage":
-		if ptv := m.GetPtvMessage(); ptv != nil {
-			raw.MediaURL, raw.Base64 = s.uploadMessageFile(ctx, instance, client, ptv, ptv.GetMimetype(), "")
-		}
-	}
+	s.downloadMessageMedia(ctx, instance, client, messageType, m, raw)
 
 	// Map MessageContextInfo (quoted, mentions, disappearing mode, external ad reply)
 	var messageContext WookMessageContextInfo
@@ -1011,6 +1277,37 @@ func (s *Whatsmiau) convertEventMessage(id string, instance *models.Instance, ev
 	}
 }
 
+// downloadMessageMedia fills the media URL and base64 of the message per the
+// storage and base64 settings.
+func (s *Whatsmiau) downloadMessageMedia(ctx context.Context, instance *models.Instance, client *whatsmeow.Client, messageType string, m *waE2E.Message, raw *WookMessageRaw) {
+	switch messageType {
+	case "imageMessage":
+		if img := m.GetImageMessage(); img != nil {
+			raw.MediaURL, raw.Base64 = s.uploadMessageFile(ctx, instance, client, img, img.GetMimetype(), "")
+		}
+	case "audioMessage":
+		if aud := m.GetAudioMessage(); aud != nil {
+			raw.MediaURL, raw.Base64 = s.uploadMessageFile(ctx, instance, client, aud, aud.GetMimetype(), "")
+		}
+	case "documentMessage":
+		if doc := m.GetDocumentMessage(); doc != nil {
+			raw.MediaURL, raw.Base64 = s.uploadMessageFile(ctx, instance, client, doc, doc.GetMimetype(), doc.GetFileName())
+		}
+	case "videoMessage":
+		if vid := m.GetVideoMessage(); vid != nil {
+			raw.MediaURL, raw.Base64 = s.uploadMessageFile(ctx, instance, client, vid, vid.GetMimetype(), "")
+		}
+	case "stickerMessage":
+		if st := m.GetStickerMessage(); st != nil {
+			raw.MediaURL, raw.Base64 = s.uploadMessageFile(ctx, instance, client, st, st.GetMimetype(), "")
+		}
+	case "ptvMessage":
+		if ptv := m.GetPtvMessage(); ptv != nil {
+			raw.MediaURL, raw.Base64 = s.uploadMessageFile(ctx, instance, client, ptv, ptv.GetMimetype(), "")
+		}
+	}
+}
+
 func (s *Whatsmiau) convertEventReceipt(id string, evt *events.Receipt) []WookMessageUpdateData {
 	var status WookMessageUpdateStatus
 	switch evt.Type {
@@ -1065,7 +1362,7 @@ func (s *Whatsmiau) uploadMessageFile(ctx context.Context, instance *models.Inst
 	}
 
 	ext = extractExtFromFile(fileName, mimetype, tmpFile)
-	if instance.Webhook.Base64 != nil && *instance.Webhook.Base64 {
+	if s.instanceWantsBase64(instance) {
 		data, err := io.ReadAll(tmpFile)
 		if err != nil {
 			zap.L().Error("failed to read image", zap.Error(err))
@@ -0,0 +1,163 @@
+package whatsmiau
+
+import (
+	"context"
+	"time"
+
+	"github.com/verbeux-ai/whatsmiau/models"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	"go.uber.org/zap"
+)
+
+// handleJoinedGroupEvent emits the metadata of a group the instance was added
+// to, created or joined through an invite.
+func (s *Whatsmiau) handleJoinedGroupEvent(id string, instance *models.Instance, e *events.JoinedGroup, eventMap map[string]bool) {
+	if !eventMap["GROUPS_UPSERT"] || instance.GroupsIgnore {
+		return
+	}
+
+	ctx, c := context.WithTimeout(context.Background(), time.Second*10)
+	defer c()
+
+	data := WookGroupsUpsertData{s.buildGroupInfoResponse(ctx, id, &e.GroupInfo, true)}
+	wookEvent := &WookEvent[WookGroupsUpsertData]{
+		Instance: instance.ID,
+		Data:     &data,
+		DateTime: time.Now(),
+		Event:    WookGroupsUpsert,
+	}
+
+	zap.L().Debug("groups upsert event", zap.String("instance", id), zap.String("group", e.JID.String()))
+	s.emit(wookEvent, instance)
+}
+
+// handleGroupChangeEvent splits a group info change into a groups.update for
+// its metadata and a group-participants.update per participant action.
+func (s *Whatsmiau) handleGroupChangeEvent(id string, instance *models.Instance, e *events.GroupInfo, eventMap map[string]bool) {
+	if instance.GroupsIgnore || (!eventMap["GROUPS_UPDATE"] && !eventMap["GROUP_PARTICIPANTS_UPDATE"]) {
+		return
+	}
+
+	ctx, c := context.WithTimeout(context.Background(), time.Second*10)
+	defer c()
+
+	var author, authorLid string
+	if e.Sender != nil {
+		author, authorLid = s.GetJidLid(ctx, id, *e.Sender)
+		if e.SenderPN != nil && !e.SenderPN.IsEmpty() {
+			author = e.SenderPN.ToNonAD().String()
+		}
+	}
+
+	if eventMap["GROUPS_UPDATE"] {
+		if update := groupUpdate(e); update != nil {
+			update.Author, update.AuthorLid = author, authorLid
+			data := WookGroupsUpdateData{*update}
+			s.emit(&WookEvent[WookGroupsUpdateData]{
+				Instance: instance.ID,
+				Data:     &data,
+				DateTime: time.Now(),
+				Event:    WookGroupsUpdate,
+			}, instance)
+		}
+	}
+
+	if !eventMap["GROUP_PARTICIPANTS_UPDATE"] {
+		return
+	}
+
+	changes := []struct {
+		action WookGroupParticipantsAction
+		jids   []types.JID
+	}{
+		{GroupParticipantsAdd, e.Join},
+		{GroupParticipantsRemove, e.Leave},
+		{GroupParticipantsPromote, e.Promote},
+		{GroupParticipantsDemote, e.Demote},
+	}
+	for _, change := range changes {
+		if len(change.jids) == 0 {
+			continue
+		}
+
+		participants := make([]string, 0, len(change.jids))
+		for _, jid := range change.jids {
+			participant, _ := s.GetJidLid(ctx, id, jid)
+			participants = append(participants, participant)
+		}
+
+		data := &WookGroupParticipantsData{
+			Id:           e.JID.String(),
+			Action:       change.action,
+			Participants: participants,
+			Author:       author,
+			AuthorLid:    authorLid,
+			InstanceId:   instance.ID,
+		}
+
+		zap.L().Debug("group participants update event", zap.String("instance", id), zap.Any("data", data))
+		s.emit(&WookEvent[WookGroupParticipantsData]{
+			Instance: instance.ID,
+			Data:     data,
+			DateTime: time.Now(),
+			Event:    WookGroupParticipantsUpdate,
+		}, instance)
+	}
+}
+
+// groupUpdate collects the metadata changes of the event, nil when it only
+// changed participants.
+func groupUpdate(e *events.GroupInfo) *WookGroupUpdate {
+	update := &WookGroupUpdate{Id: e.JID.String()}
+	changed := false
+
+	if e.Name != nil {
+		update.Subject = &e.Name.Name
+		changed = true
+	}
+	if e.Topic != nil {
+		update.Desc = &e.Topic.Topic
+		update.DescId = e.Topic.TopicID
+		changed = true
+	}
+	if e.Locked != nil {
+		update.Restrict = &e.Locked.IsLocked
+		changed = true
+	}
+	if e.Announce != nil {
+		update.Announce = &e.Announce.IsAnnounce
+		changed = true
+	}
+	if e.Ephemeral != nil {
+		timer := e.Ephemeral.DisappearingTimer
+		update.Ephemeral = &timer
+		changed = true
+	}
+	if e.MembershipApprovalMode != nil {
+		update.JoinApprovalMode = &e.MembershipApprovalMode.IsJoinApprovalRequired
+		changed = true
+	}
+	if e.NewInviteLink != nil {
+		update.InviteLink = *e.NewInviteLink
+		changed = true
+	}
+	if e.Delete != nil {
+		update.Deleted = e.Delete.Deleted
+		changed = true
+	}
+	if e.Suspended || e.Unsuspended {
+		suspended := e.Suspended
+		update.Suspended = &suspended
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+	if !e.Timestamp.IsZero() {
+		update.Timestamp = e.Timestamp.Unix()
+	}
+
+	return update
+}
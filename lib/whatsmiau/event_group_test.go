@@ -0,0 +1,43 @@
+package whatsmiau
+
+import (
+	"testing"
+
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+func TestGroupUpdateKeepsOnlyChangedFields(t *testing.T) {
+	group := types.NewJID("120363000000000000", types.GroupServer)
+
+	if update := groupUpdate(&events.GroupInfo{JID: group, Join: []types.JID{types.NewJID("5511999999999", types.DefaultUserServer)}}); update != nil {
+		t.Fatalf("participant only change produced %+v", update)
+	}
+
+	update := groupUpdate(&events.GroupInfo{
+		JID:      group,
+		Name:     &types.GroupName{Name: "Support"},
+		Announce: &types.GroupAnnounce{IsAnnounce: true},
+	})
+	if update == nil {
+		t.Fatal("metadata change produced no update")
+	}
+	if update.Subject == nil || *update.Subject != "Support" || update.Announce == nil || !*update.Announce {
+		t.Errorf("unexpected update %+v", update)
+	}
+	if update.Desc != nil || update.Restrict != nil {
+		t.Errorf("unchanged fields set in %+v", update)
+	}
+}
+
+func TestGroupParticipantsEventNames(t *testing.T) {
+	if got := WookGroupParticipantsUpdate.ConfigName(); got != "GROUP_PARTICIPANTS_UPDATE" {
+		t.Errorf("ConfigName = %q", got)
+	}
+	if got := WookGroupParticipantsUpdate.Path(); got != "group-participants-update" {
+		t.Errorf("Path = %q", got)
+	}
+	if wook, ok := ParseWook("GROUP_PARTICIPANTS_UPDATE"); !ok || wook != WookGroupParticipantsUpdate {
+		t.Errorf("ParseWook = %q, %v", wook, ok)
+	}
+}
@@ -0,0 +1,227 @@
+package whatsmiau
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/verbeux-ai/whatsmiau/env"
+	"github.com/verbeux-ai/whatsmiau/models"
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waHistorySync"
+	"go.mau.fi/whatsmeow/types"
+	"go.uber.org/zap"
+)
+
+// handleHistoryProgress reports how far the history sync is, so onboarding
+// can show it while the chunks are processed.
+func (s *Whatsmiau) handleHistoryProgress(instance *models.Instance, data *waHistorySync.HistorySync, eventMap map[string]bool) {
+	if !eventMap["HISTORY_SYNC_PROGRESS"] {
+		return
+	}
+
+	messages := 0
+	for _, conversation := range data.GetConversations() {
+		messages += len(conversation.GetMessages())
+	}
+
+	progress := &WookHistorySyncProgressData{
+		SyncType:      data.GetSyncType().String(),
+		ChunkOrder:    data.GetChunkOrder(),
+		Progress:      data.GetProgress(),
+		Completed:     data.GetProgress() >= 100,
+		Conversations: len(data.GetConversations()),
+		Messages:      messages,
+		InstanceId:    instance.ID,
+	}
+
+	zap.L().Debug("history sync progress", zap.String("instance", instance.ID), zap.Any("data", progress))
+	s.emit(&WookEvent[WookHistorySyncProgressData]{
+		Instance: instance.ID,
+		Data:     progress,
+		DateTime: time.Now(),
+		Event:    WookHistorySyncProgress,
+	}, instance)
+}
+
+// handleHistoryChats emits the conversations of a history sync chunk as
+// chats.set pages.
+func (s *Whatsmiau) handleHistoryChats(id string, instance *models.Instance, data *waHistorySync.HistorySync, eventMap map[string]bool) {
+	if !eventMap["CHATS_SET"] {
+		return
+	}
+
+	ctx, c := context.WithTimeout(context.Background(), time.Second*30)
+	defer c()
+
+	chats := make([]WookChat, 0, len(data.GetConversations()))
+	for _, conversation := range data.GetConversations() {
+		jid, ok := historyChatJID(conversation, instance)
+		if !ok {
+			continue
+		}
+
+		remoteJid, remoteLid := s.GetJidLid(ctx, id, jid)
+		name := conversation.GetName()
+		if name == "" {
+			name = conversation.GetDisplayName()
+		}
+
+		chats = append(chats, WookChat{
+			RemoteJid:             remoteJid,
+			RemoteLid:             remoteLid,
+			Name:                  name,
+			UnreadCount:           conversation.GetUnreadCount(),
+			ConversationTimestamp: conversation.GetConversationTimestamp(),
+			Archived:              conversation.GetArchived(),
+			Pinned:                conversation.GetPinned() > 0,
+			ReadOnly:              conversation.GetReadOnly(),
+			EphemeralExpiration:   conversation.GetEphemeralExpiration(),
+			MuteEndTime:           conversation.GetMuteEndTime(),
+		})
+	}
+
+	pages := historyPages(chats, env.Env.HistorySyncPageSize)
+	for i, page := range pages {
+		s.emit(&WookEvent[WookChatsSetData]{
+			Instance: instance.ID,
+			Data: &WookChatsSetData{
+				WookHistorySync: historySyncPage(data, i, len(pages)),
+				Chats:           page,
+				InstanceId:      instance.ID,
+			},
+			DateTime: time.Now(),
+			Event:    WookChatsSet,
+		}, instance)
+	}
+}
+
+// handleHistoryMessages converts the past messages of every conversation of a
+// history sync chunk and emits them per chat, oldest first, as messages.set
+// pages. A full sync can hold years of messages, so the chunk is converted in
+// the background: the chunks of an instance one after another, and up to
+// HISTORY_SYNC_WORKERS conversations at a time across instances. Media follows
+// the base64 and storage settings of the instance, like new messages.
+func (s *Whatsmiau) handleHistoryMessages(id string, instance *models.Instance, data *waHistorySync.HistorySync, eventMap map[string]bool) {
+	if !eventMap["MESSAGES_SET"] {
+		return
+	}
+
+	client, ok := s.clients.Load(id)
+	if !ok {
+		return
+	}
+
+	s.historyQueue.run(id, func() {
+		var wg sync.WaitGroup
+		for _, conversation := range data.GetConversations() {
+			jid, ok := historyChatJID(conversation, instance)
+			if !ok {
+				continue
+			}
+
+			s.historyWorkers <- struct{}{}
+			wg.Add(1)
+			go func() {
+				defer func() {
+					<-s.historyWorkers
+					wg.Done()
+				}()
+				s.emitHistoryConversation(id, instance, client, data, jid, conversation)
+			}()
+		}
+		wg.Wait()
+	})
+}
+
+func (s *Whatsmiau) emitHistoryConversation(id string, instance *models.Instance, client *whatsmeow.Client, data *waHistorySync.HistorySync, jid types.JID, conversation *waHistorySync.Conversation) {
+	messages := make([]WookMessageData, 0, len(conversation.GetMessages()))
+	for _, historyMsg := range conversation.GetMessages() {
+		evt, err := client.ParseWebMessage(jid, historyMsg.GetMessage())
+		if err != nil {
+			zap.L().Debug("failed to parse history message", zap.String("instance", id), zap.String("chat", jid.String()), zap.Error(err))
+			continue
+		}
+
+		message := s.convertEventMessage(id, instance, evt)
+		if message == nil {
+			continue
+		}
+		message.InstanceId = instance.ID
+		messages = append(messages, *message)
+	}
+	if len(messages) == 0 {
+		return
+	}
+
+	slices.SortStableFunc(messages, func(a, b WookMessageData) int {
+		return a.MessageTimestamp - b.MessageTimestamp
+	})
+
+	ctx, c := context.WithTimeout(context.Background(), time.Second*5)
+	remoteJid, remoteLid := s.GetJidLid(ctx, id, jid)
+	c()
+
+	pages := historyPages(messages, env.Env.HistorySyncPageSize)
+	for i, page := range pages {
+		s.emit(&WookEvent[WookMessagesSetData]{
+			Instance: instance.ID,
+			Data: &WookMessagesSetData{
+				WookHistorySync: historySyncPage(data, i, len(pages)),
+				RemoteJid:       remoteJid,
+				RemoteLid:       remoteLid,
+				Messages:        page,
+				InstanceId:      instance.ID,
+			},
+			DateTime: time.Now(),
+			Event:    WookMessagesSet,
+		}, instance)
+	}
+
+	zap.L().Debug("history messages emitted", zap.String("instance", id), zap.String("chat", remoteJid), zap.Int("messages", len(messages)))
+}
+
+// historyChatJID skips status broadcasts, and groups when they are ignored.
+func historyChatJID(conversation *waHistorySync.Conversation, instance *models.Instance) (types.JID, bool) {
+	jid, err := types.ParseJID(conversation.GetID())
+	if err != nil {
+		zap.L().Debug("failed to parse history chat", zap.String("chat", conversation.GetID()), zap.Error(err))
+		return types.JID{}, false
+	}
+
+	if jid.Server == types.BroadcastServer {
+		return types.JID{}, false
+	}
+	if instance.GroupsIgnore && jid.Server == types.GroupServer {
+		return types.JID{}, false
+	}
+
+	return jid, true
+}
+
+func historySyncPage(data *waHistorySync.HistorySync, page, pages int) WookHistorySync {
+	return WookHistorySync{
+		SyncType:   data.GetSyncType().String(),
+		ChunkOrder: data.GetChunkOrder(),
+		Progress:   data.GetProgress(),
+		Page:       page + 1,
+		LastPage:   page == pages-1,
+	}
+}
+
+// historyPages splits items in pages of at most size items.
+func historyPages[T any](items []T, size int) [][]T {
+	if size <= 0 {
+		size = len(items)
+	}
+
+	var pages [][]T
+	for len(items) > 0 {
+		n := min(size, len(items))
+		pages = append(pages, items[:n:n])
+		items = items[n:]
+	}
+
+	return pages
+}
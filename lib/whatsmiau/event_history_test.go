@@ -0,0 +1,56 @@
+package whatsmiau
+
+import (
+	"testing"
+
+	"github.com/verbeux-ai/whatsmiau/models"
+	"go.mau.fi/whatsmeow/proto/waHistorySync"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestHistoryPages(t *testing.T) {
+	pages := historyPages([]int{1, 2, 3, 4, 5}, 2)
+	if len(pages) != 3 || len(pages[0]) != 2 || len(pages[2]) != 1 || pages[2][0] != 5 {
+		t.Fatalf("unexpected pages: %v", pages)
+	}
+
+	if pages := historyPages([]int{1, 2, 3}, 0); len(pages) != 1 || len(pages[0]) != 3 {
+		t.Fatalf("expected a single page without a size, got %v", pages)
+	}
+
+	if pages := historyPages([]int{}, 2); len(pages) != 0 {
+		t.Fatalf("expected no pages, got %v", pages)
+	}
+}
+
+func TestHistoryChatJID(t *testing.T) {
+	instance := &models.Instance{GroupsIgnore: true}
+	for chat, expected := range map[string]bool{
+		"5511999999999@s.whatsapp.net": true,
+		"120363000000000000@g.us":      false,
+		"status@broadcast":             false,
+	} {
+		_, ok := historyChatJID(&waHistorySync.Conversation{ID: proto.String(chat)}, instance)
+		if ok != expected {
+			t.Fatalf("%s: expected %v, got %v", chat, expected, ok)
+		}
+	}
+}
+
+func TestWithoutBase64MessagesSet(t *testing.T) {
+	event := &WookEvent[WookMessagesSetData]{
+		Event: WookMessagesSet,
+		Data: &WookMessagesSetData{Messages: []WookMessageData{
+			{Message: &WookMessageRaw{Conversation: "text"}},
+			{Message: &WookMessageRaw{Base64: "aGk="}},
+		}},
+	}
+
+	stripped := event.withoutBase64().(*WookEvent[WookMessagesSetData])
+	if stripped.Data.Messages[1].Message.Base64 != "" {
+		t.Fatal("expected base64 to be stripped")
+	}
+	if event.Data.Messages[1].Message.Base64 == "" {
+		t.Fatal("expected the original event to keep its base64")
+	}
+}
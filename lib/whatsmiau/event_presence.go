@@ -0,0 +1,77 @@
+package whatsmiau
+
+import (
+	"context"
+	"time"
+
+	"github.com/verbeux-ai/whatsmiau/models"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	"go.uber.org/zap"
+)
+
+// handlePresenceEvent reports a contact going online or offline. WhatsApp
+// only sends these for contacts subscribed with SubscribePresence.
+func (s *Whatsmiau) handlePresenceEvent(id string, instance *models.Instance, e *events.Presence, eventMap map[string]bool) {
+	if !eventMap["PRESENCE_UPDATE"] {
+		return
+	}
+
+	presence := WookPresence{LastKnownPresence: "available"}
+	if e.Unavailable {
+		presence.LastKnownPresence = "unavailable"
+	}
+	if !e.LastSeen.IsZero() {
+		presence.LastSeen = e.LastSeen.Unix()
+	}
+
+	ctx, c := context.WithTimeout(context.Background(), time.Second*5)
+	defer c()
+
+	jid, _ := s.GetJidLid(ctx, id, e.From)
+	s.emitPresence(instance, jid, jid, presence)
+}
+
+// handleChatPresenceEvent reports a contact typing or recording in a chat.
+func (s *Whatsmiau) handleChatPresenceEvent(id string, instance *models.Instance, e *events.ChatPresence, eventMap map[string]bool) {
+	if !eventMap["PRESENCE_UPDATE"] {
+		return
+	}
+
+	if canIgnoreGroup(e, instance) {
+		return
+	}
+
+	state := string(e.State)
+	if e.State == types.ChatPresenceComposing && e.Media == types.ChatPresenceMediaAudio {
+		state = "recording"
+	}
+
+	ctx, c := context.WithTimeout(context.Background(), time.Second*5)
+	defer c()
+
+	chat, _ := s.GetJidLid(ctx, id, e.Chat)
+	participant := chat
+	if e.IsGroup {
+		participant, _ = s.GetJidLid(ctx, id, e.Sender)
+	}
+
+	s.emitPresence(instance, chat, participant, WookPresence{LastKnownPresence: state})
+}
+
+func (s *Whatsmiau) emitPresence(instance *models.Instance, chat, participant string, presence WookPresence) {
+	data := &WookPresenceData{
+		Id:        chat,
+		Presences: map[string]WookPresence{participant: presence},
+	}
+
+	wookEvent := &WookEvent[WookPresenceData]{
+		Instance: instance.ID,
+		Data:     data,
+		DateTime: time.Now(),
+		Event:    WookPresenceUpdate,
+	}
+
+	zap.L().Debug("presence update event", zap.String("instance", instance.ID), zap.Any("data", data))
+	s.emit(wookEvent, instance)
+}
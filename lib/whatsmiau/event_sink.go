@@ -0,0 +1,267 @@
+package whatsmiau
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/verbeux-ai/whatsmiau/env"
+	"github.com/verbeux-ai/whatsmiau/interfaces"
+	"github.com/verbeux-ai/whatsmiau/lib/sinks/amqp"
+	"github.com/verbeux-ai/whatsmiau/lib/sinks/nats"
+	"github.com/verbeux-ai/whatsmiau/models"
+	"go.uber.org/zap"
+	"golang.org/x/net/context"
+)
+
+var (
+	_ interfaces.EventSink = (*httpSink)(nil)
+	_ interfaces.EventSink = (*brokerSink)(nil)
+)
+
+var (
+	errUnknownSink    = errors.New("unknown event sink type")
+	errSinkBufferFull = errors.New("event sink buffer is full")
+	errSinkClosed     = errors.New("event sink is closed")
+)
+
+// httpSink is the webhook delivery of a target: events are queued on the
+// emitter stream and posted by the workers, with retries and dead letters.
+type httpSink struct {
+	s      *Whatsmiau
+	target webhookTarget
+	key    string // order key, see orderKey
+	chat   wookChat
+}
+
+func (h *httpSink) Publish(_ context.Context, instance, event string, data []byte) error {
+	url := webhookURL(h.target.webhook, Wook(event))
+	if url == "" {
+		return nil
+	}
+
+	return h.s.enqueueEmit(emitter{
+		instance:     instance,
+		subscription: h.target.subscriptionID(),
+		event:        Wook(event),
+		url:          url,
+		data:         data,
+		key:          h.key,
+		messageID:    h.chat.messageID,
+	})
+}
+
+func (h *httpSink) Close() {}
+
+// brokerSink publishes to a broker connection from its own goroutine, in the
+// order the events were queued, so event handlers never wait on the broker.
+// Events beyond EVENT_SINK_BUFFER_SIZE are dropped.
+type brokerSink struct {
+	conn  interfaces.EventSink
+	queue chan brokerEvent
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+type brokerEvent struct {
+	instance string
+	event    string
+	data     []byte
+}
+
+func newBrokerSink(conn interfaces.EventSink, size int) *brokerSink {
+	if size <= 0 {
+		size = 1024
+	}
+
+	b := &brokerSink{conn: conn, queue: make(chan brokerEvent, size)}
+	go b.run()
+	return b
+}
+
+func (b *brokerSink) Publish(_ context.Context, instance, event string, data []byte) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if b.closed {
+		return errSinkClosed
+	}
+
+	select {
+	case b.queue <- brokerEvent{instance: instance, event: event, data: data}:
+		return nil
+	default:
+		return errSinkBufferFull
+	}
+}
+
+// Close publishes what is still queued, then closes the connection.
+func (b *brokerSink) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.closed {
+		b.closed = true
+		close(b.queue)
+	}
+}
+
+func (b *brokerSink) run() {
+	defer b.conn.Close()
+
+	for e := range b.queue {
+		ctx, c := context.WithTimeout(context.Background(), time.Second*5)
+		if err := b.conn.Publish(ctx, e.instance, e.event, e.data); err != nil {
+			zap.L().Error("failed to publish event",
+				zap.String("instance", e.instance),
+				zap.String("event", e.event),
+				zap.Error(err),
+			)
+		}
+		c()
+	}
+}
+
+func (s *Whatsmiau) publish(target webhookTarget, instance string, event Wook, chat wookChat, data []byte) {
+	sink, err := s.targetSink(target, instance, chat, orderKey(target, instance, chat))
+	if err != nil {
+		zap.L().Error("failed to connect event sink",
+			zap.String("type", string(target.broker.Type)),
+			zap.String("instance", instance),
+			zap.Error(err),
+		)
+		return
+	}
+
+	ctx, c := context.WithTimeout(context.Background(), time.Second*5)
+	defer c()
+
+	if err := sink.Publish(ctx, instance, string(event), data); err != nil {
+		zap.L().Error("failed to publish event",
+			zap.String("instance", instance),
+			zap.String("event", string(event)),
+			zap.Error(err),
+		)
+	}
+}
+
+// sharedSink is a broker connection with the number of owners (instances, or
+// the global sink) using it.
+type sharedSink struct {
+	sink   interfaces.EventSink
+	owners int
+}
+
+// targetSink returns the sink of a target; the chat and order key are passed on
+// to webhook deliveries. Broker connections are shared by every target with the
+// same settings. Each instance (and the global sink) owns one of them, and a
+// connection is closed once it has no owner left. Ownership and closing happen
+// under sinkMu, so a connection handed out is never one about to close.
+func (s *Whatsmiau) targetSink(target webhookTarget, instance string, chat wookChat, order string) (interfaces.EventSink, error) {
+	if target.broker == nil {
+		return &httpSink{s: s, target: target, key: order, chat: chat}, nil
+	}
+
+	owner := instance
+	if target.global {
+		owner = globalWebhookID
+	}
+
+	broker := target.broker
+	key := string(broker.Type) + "\n" + broker.Url + "\n" + broker.Prefix + "\n" + broker.Exchange
+
+	s.sinkMu.Lock()
+	defer s.sinkMu.Unlock()
+
+	if s.sinkOwners[owner] == key {
+		return s.sinks[key].sink, nil
+	}
+
+	shared, ok := s.sinks[key]
+	if !ok {
+		var conn interfaces.EventSink
+		var err error
+		switch broker.Type {
+		case models.EventSinkNats:
+			conn, err = nats.New(broker.Url, broker.Prefix)
+		case models.EventSinkAmqp:
+			conn, err = amqp.New(broker.Url, broker.Exchange)
+		default:
+			err = errUnknownSink
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		shared = &sharedSink{sink: newBrokerSink(conn, env.Env.EventSinkBuffer)}
+		s.sinks[key] = shared
+	}
+
+	if previous, ok := s.sinkOwners[owner]; ok {
+		s.releaseSink(previous)
+	}
+	s.sinkOwners[owner] = key
+	shared.owners++
+
+	return shared.sink, nil
+}
+
+// SinkUpdated drops the broker connection of an instance whose sink changed;
+// the next event connects with the new settings.
+func (s *Whatsmiau) SinkUpdated(instanceID string) {
+	s.sinkMu.Lock()
+	defer s.sinkMu.Unlock()
+
+	if key, ok := s.sinkOwners[instanceID]; ok {
+		delete(s.sinkOwners, instanceID)
+		s.releaseSink(key)
+	}
+}
+
+// releaseSink drops an owner of the broker connection of key, closing it with
+// the last one. sinkMu must be held.
+func (s *Whatsmiau) releaseSink(key string) {
+	shared, ok := s.sinks[key]
+	if !ok {
+		return
+	}
+
+	if shared.owners--; shared.owners > 0 {
+		return
+	}
+
+	delete(s.sinks, key)
+	shared.sink.Close()
+}
+
+// brokerTarget turns a sink config into a target; the webhook only carries
+// the event and base64 settings used for filtering and encoding.
+func brokerTarget(sink *models.InstanceSink, global bool) webhookTarget {
+	return webhookTarget{
+		webhook: &models.InstanceWebhook{
+			Enabled: sink.Enabled,
+			Base64:  sink.Base64,
+			Events:  sink.Events,
+		},
+		broker: sink,
+		global: global,
+	}
+}
+
+// newGlobalSink builds the broker sink that receives the events of every
+// instance. It is nil when EVENT_SINK_TYPE is not set.
+func newGlobalSink(e env.E) *models.InstanceSink {
+	if e.EventSinkType == "" {
+		return nil
+	}
+
+	return &models.InstanceSink{
+		Type:     models.EventSinkType(e.EventSinkType),
+		Url:      e.EventSinkURL,
+		Prefix:   e.EventSinkPrefix,
+		Exchange: e.EventSinkExchange,
+		Base64:   &e.EventSinkBase64,
+		Events:   allEventsIfEmpty(e.EventSinkEvents),
+	}
+}
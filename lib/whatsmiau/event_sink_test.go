@@ -0,0 +1,93 @@
+package whatsmiau
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/verbeux-ai/whatsmiau/models"
+	"golang.org/x/net/context"
+)
+
+// blockingConn records the events published to it, waiting on release before
+// each one.
+type blockingConn struct {
+	mu      sync.Mutex
+	events  []string
+	release chan struct{}
+	closed  chan struct{}
+}
+
+func (c *blockingConn) Publish(_ context.Context, _, event string, _ []byte) error {
+	<-c.release
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.events = append(c.events, event)
+	return nil
+}
+
+func (c *blockingConn) Close() { close(c.closed) }
+
+func TestBrokerSinkPublishesInBackground(t *testing.T) {
+	conn := &blockingConn{release: make(chan struct{}), closed: make(chan struct{})}
+	sink := newBrokerSink(conn, 2)
+
+	// the first event is taken by the publisher, two more fill the buffer
+	for _, event := range []string{"a", "b", "c"} {
+		if err := sink.Publish(context.Background(), "acme", event, nil); err != nil {
+			t.Fatalf("publish %s: %v", event, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := sink.Publish(context.Background(), "acme", "d", nil); !errors.Is(err, errSinkBufferFull) {
+		t.Fatalf("expected a full buffer, got %v", err)
+	}
+
+	sink.Close()
+	if err := sink.Publish(context.Background(), "acme", "e", nil); !errors.Is(err, errSinkClosed) {
+		t.Fatalf("expected a closed sink, got %v", err)
+	}
+
+	close(conn.release)
+	select {
+	case <-conn.closed:
+	case <-time.After(time.Second):
+		t.Fatal("connection was not closed")
+	}
+	if len(conn.events) != 3 || conn.events[0] != "a" || conn.events[2] != "c" {
+		t.Errorf("expected the queued events in order, got %v", conn.events)
+	}
+}
+
+func TestReleaseSinkKeepsSharedConnections(t *testing.T) {
+	conn := &blockingConn{release: make(chan struct{}), closed: make(chan struct{})}
+	s := &Whatsmiau{
+		sinks:      map[string]*sharedSink{"nats": {sink: newBrokerSink(conn, 1), owners: 2}},
+		sinkOwners: map[string]string{"a": "nats", "b": "nats"},
+	}
+
+	s.SinkUpdated("a")
+	if _, ok := s.sinks["nats"]; !ok {
+		t.Fatal("connection used by another instance was released")
+	}
+
+	// an instance changing to another connection releases its old one
+	broker := &models.InstanceSink{Type: models.EventSinkAmqp}
+	amqpKey := string(broker.Type) + "\n\n\n"
+	s.sinks[amqpKey] = &sharedSink{sink: newBrokerSink(&blockingConn{release: make(chan struct{}), closed: make(chan struct{})}, 1)}
+	if _, err := s.targetSink(brokerTarget(broker, false), "b", wookChat{}, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.sinks["nats"]; ok {
+		t.Fatal("unused connection was kept")
+	}
+	select {
+	case <-conn.closed:
+	case <-time.After(time.Second):
+		t.Fatal("unused connection was not closed")
+	}
+	if s.sinkOwners["b"] != amqpKey || s.sinks[amqpKey].owners != 1 {
+		t.Fatalf("instance does not own its new connection: %v", s.sinkOwners)
+	}
+}
@@ -0,0 +1,188 @@
+package whatsmiau
+
+import (
+	"encoding/json"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+)
+
+// eventStream fans the emitted events out to the live WebSocket subscribers.
+// Delivery is best effort: unlike webhooks nothing is queued on Redis, and a
+// subscriber that does not keep up with its buffer is dropped instead of
+// slowing the event handlers down.
+type eventStream struct {
+	mu          sync.RWMutex
+	subscribers map[*EventSubscription]struct{}
+	bufferSize  int
+}
+
+func newEventStream(bufferSize int) *eventStream {
+	if bufferSize <= 0 {
+		bufferSize = 256
+	}
+
+	return &eventStream{
+		subscribers: make(map[*EventSubscription]struct{}),
+		bufferSize:  bufferSize,
+	}
+}
+
+// EventSubscription is a single stream consumer. Instance is empty for
+// consumers of every instance, which also receive the application events.
+type EventSubscription struct {
+	instance   string
+	base64     bool
+	events     atomic.Pointer[[]string]
+	ch         chan []byte
+	done       chan struct{}
+	closeOnce  sync.Once
+	overflowed atomic.Bool
+	stream     *eventStream
+}
+
+// SubscribeEvents registers a stream consumer for an instance, or for all of
+// them when instance is empty. No events means every event.
+func (s *Whatsmiau) SubscribeEvents(instance string, events []string, base64 bool) *EventSubscription {
+	sub := &EventSubscription{
+		instance: instance,
+		base64:   base64,
+		ch:       make(chan []byte, s.stream.bufferSize),
+		done:     make(chan struct{}),
+		stream:   s.stream,
+	}
+	sub.SetEvents(events)
+
+	s.stream.mu.Lock()
+	s.stream.subscribers[sub] = struct{}{}
+	s.stream.mu.Unlock()
+
+	return sub
+}
+
+// C delivers the encoded events.
+func (sub *EventSubscription) C() <-chan []byte {
+	return sub.ch
+}
+
+// Done is closed once the subscription ends, either through Close or because
+// the consumer fell behind.
+func (sub *EventSubscription) Done() <-chan struct{} {
+	return sub.done
+}
+
+// Overflowed reports whether the subscription was dropped for being slow.
+func (sub *EventSubscription) Overflowed() bool {
+	return sub.overflowed.Load()
+}
+
+// SetEvents replaces the event filter (e.g. MESSAGES_UPSERT); empty for all.
+func (sub *EventSubscription) SetEvents(events []string) {
+	sub.events.Store(&events)
+}
+
+func (sub *EventSubscription) Close() {
+	sub.closeOnce.Do(func() {
+		sub.stream.mu.Lock()
+		delete(sub.stream.subscribers, sub)
+		sub.stream.mu.Unlock()
+		close(sub.done)
+	})
+}
+
+func (sub *EventSubscription) accepts(event Wook) bool {
+	events := *sub.events.Load()
+	return len(events) == 0 || slices.Contains(events, event.ConfigName())
+}
+
+func (sub *EventSubscription) send(data []byte) {
+	select {
+	case sub.ch <- data:
+	default:
+		if sub.overflowed.CompareAndSwap(false, true) {
+			zap.L().Warn("dropping slow event stream consumer", zap.String("instance", sub.instance))
+			go sub.Close()
+		}
+	}
+}
+
+// publish hands the event to every subscriber of its instance and to the
+// global ones. Application events (application set) only go to the latter.
+func (e *eventStream) publish(body wookPayload, application bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if len(e.subscribers) == 0 {
+		return
+	}
+
+	instance := body.instanceID()
+	event := body.eventType()
+	encoded := make(map[bool][]byte)
+	for sub := range e.subscribers {
+		if sub.instance != "" && (application || sub.instance != instance) {
+			continue
+		}
+
+		if !sub.accepts(event) || sub.overflowed.Load() {
+			continue
+		}
+
+		data, ok := encoded[sub.base64]
+		if !ok {
+			payload := body
+			if !sub.base64 {
+				payload = body.withoutBase64()
+			}
+
+			var err error
+			data, err = json.Marshal(payload)
+			if err != nil {
+				zap.L().Error("failed to marshal stream event", zap.Error(err))
+				return
+			}
+			encoded[sub.base64] = data
+		}
+
+		sub.send(data)
+	}
+}
+
+// subscribedEvents adds the events the stream consumers of the instance want.
+func (e *eventStream) subscribedEvents(instance string, eventMap map[string]bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	for sub := range e.subscribers {
+		if sub.instance != "" && sub.instance != instance {
+			continue
+		}
+
+		events := *sub.events.Load()
+		if len(events) == 0 {
+			for _, wook := range allWooks {
+				eventMap[wook.ConfigName()] = true
+			}
+			continue
+		}
+
+		for _, event := range events {
+			eventMap[event] = true
+		}
+	}
+}
+
+func (e *eventStream) wantsBase64(instance string) bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	for sub := range e.subscribers {
+		if sub.base64 && (sub.instance == "" || sub.instance == instance) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,73 @@
+package whatsmiau
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func newStreamEvent(instance string, event Wook) *WookEvent[WookConnectionUpdateData] {
+	return &WookEvent[WookConnectionUpdateData]{
+		Instance: instance,
+		Data:     &WookConnectionUpdateData{Instance: instance, State: "open"},
+		DateTime: time.Now(),
+		Event:    event,
+	}
+}
+
+func TestEventStreamPublish(t *testing.T) {
+	s := &Whatsmiau{stream: newEventStream(4)}
+
+	instanceSub := s.SubscribeEvents("a", []string{"CONNECTION_UPDATE"}, false)
+	defer instanceSub.Close()
+	globalSub := s.SubscribeEvents("", nil, false)
+	defer globalSub.Close()
+
+	s.stream.publish(newStreamEvent("a", WookConnectionUpdate), false)
+	s.stream.publish(newStreamEvent("b", WookConnectionUpdate), false)
+	s.stream.publish(newStreamEvent("a", WookInstanceCreate), true)
+
+	if got := len(instanceSub.C()); got != 1 {
+		t.Fatalf("instance subscriber got %d events, want 1", got)
+	}
+	var body WookEvent[WookConnectionUpdateData]
+	if err := json.Unmarshal(<-instanceSub.C(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Instance != "a" || body.Event != WookConnectionUpdate {
+		t.Errorf("unexpected event %+v", body)
+	}
+
+	if got := len(globalSub.C()); got != 3 {
+		t.Errorf("global subscriber got %d events, want 3", got)
+	}
+
+	eventMap := make(map[string]bool)
+	s.stream.subscribedEvents("a", eventMap)
+	if !eventMap["MESSAGES_UPSERT"] || !eventMap["CONNECTION_UPDATE"] {
+		t.Errorf("expected all events from the global subscriber, got %v", eventMap)
+	}
+}
+
+func TestEventStreamSlowConsumer(t *testing.T) {
+	s := &Whatsmiau{stream: newEventStream(1)}
+	sub := s.SubscribeEvents("a", nil, false)
+
+	s.stream.publish(newStreamEvent("a", WookConnectionUpdate), false)
+	s.stream.publish(newStreamEvent("a", WookConnectionUpdate), false)
+
+	select {
+	case <-sub.Done():
+	case <-time.After(time.Second):
+		t.Fatal("slow consumer was not dropped")
+	}
+	if !sub.Overflowed() {
+		t.Error("expected subscription to be marked as overflowed")
+	}
+
+	s.stream.mu.RLock()
+	defer s.stream.mu.RUnlock()
+	if len(s.stream.subscribers) != 0 {
+		t.Errorf("expected subscriber to be removed, %d left", len(s.stream.subscribers))
+	}
+}
@@ -365,6 +365,13 @@ func canIgnoreGroup(evt interface{}, instance *models.Instance) bool {
 		}
 
 		jid = pushName.JID.String()
+	case *events.ChatPresence:
+		presence, ok := evt.(*events.ChatPresence)
+		if !ok {
+			return false
+		}
+
+		jid = presence.Chat.String()
 	}
 
 	return strings.HasSuffix(jid, "@g.us")
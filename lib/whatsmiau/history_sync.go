@@ -0,0 +1,73 @@
+package whatsmiau
+
+import (
+	"github.com/verbeux-ai/whatsmiau/env"
+	"github.com/verbeux-ai/whatsmiau/models"
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waCompanionReg"
+	"go.mau.fi/whatsmeow/proto/waWa6"
+	"go.mau.fi/whatsmeow/store"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+)
+
+// configHistorySync makes the client pair with the history sync settings of
+// the instance. WhatsApp only reads the device props when a device is paired,
+// so they are applied to the registration payload alone; the instance is
+// looked up at that moment so settings changed before scanning the QR code
+// still count.
+func (s *Whatsmiau) configHistorySync(client *whatsmeow.Client, id string) {
+	client.GetClientPayload = func() *waWa6.ClientPayload {
+		payload := client.Store.GetClientPayload()
+		if payload.GetDevicePairingData() == nil {
+			return payload
+		}
+
+		// pairing goes on with the default props when the instance cannot be read
+		instance, err := s.lookupInstance(id)
+		if err != nil {
+			zap.L().Error("failed to get instance for history sync settings", zap.String("instance", id), zap.Error(err))
+			return payload
+		}
+		if instance == nil {
+			return payload
+		}
+
+		props, err := proto.Marshal(historyDeviceProps(instance))
+		if err != nil {
+			zap.L().Error("failed to marshal device props", zap.String("instance", id), zap.Error(err))
+			return payload
+		}
+		payload.DevicePairingData.DeviceProps = props
+
+		return payload
+	}
+}
+
+// historyDeviceProps are the default device props with the history requested
+// by the instance: SyncFullHistory asks for the whole history, bounded by
+// HISTORY_SYNC_FULL_DAYS and HISTORY_SYNC_FULL_SIZE_MB, and SyncRecentHistory
+// limits the recent sync to HISTORY_SYNC_RECENT_DAYS.
+func historyDeviceProps(instance *models.Instance) *waCompanionReg.DeviceProps {
+	props := proto.Clone(store.DeviceProps).(*waCompanionReg.DeviceProps)
+	if props.HistorySyncConfig == nil {
+		props.HistorySyncConfig = &waCompanionReg.DeviceProps_HistorySyncConfig{}
+	}
+	config := props.HistorySyncConfig
+
+	if instance.SyncFullHistory {
+		props.RequireFullSync = proto.Bool(true)
+		if env.Env.HistorySyncFullDays > 0 {
+			config.FullSyncDaysLimit = proto.Uint32(env.Env.HistorySyncFullDays)
+		}
+		if env.Env.HistorySyncFullSizeMB > 0 {
+			config.FullSyncSizeMbLimit = proto.Uint32(env.Env.HistorySyncFullSizeMB)
+		}
+	}
+
+	if instance.SyncRecentHistory && env.Env.HistorySyncRecentDays > 0 {
+		config.RecentSyncDaysLimit = proto.Uint32(env.Env.HistorySyncRecentDays)
+	}
+
+	return props
+}
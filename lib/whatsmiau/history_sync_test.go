@@ -0,0 +1,36 @@
+package whatsmiau
+
+import (
+	"testing"
+
+	"github.com/verbeux-ai/whatsmiau/env"
+	"github.com/verbeux-ai/whatsmiau/models"
+	"go.mau.fi/whatsmeow/store"
+)
+
+func TestHistoryDeviceProps(t *testing.T) {
+	env.Env.HistorySyncFullDays = 365
+	env.Env.HistorySyncFullSizeMB = 0
+	env.Env.HistorySyncRecentDays = 3
+
+	props := historyDeviceProps(&models.Instance{})
+	if props.GetRequireFullSync() || props.GetHistorySyncConfig().RecentSyncDaysLimit != nil {
+		t.Fatalf("expected the default props, got %v", props)
+	}
+
+	props = historyDeviceProps(&models.Instance{SyncFullHistory: true, SyncRecentHistory: true})
+	config := props.GetHistorySyncConfig()
+	if !props.GetRequireFullSync() {
+		t.Fatal("expected full sync to be required")
+	}
+	if config.GetFullSyncDaysLimit() != 365 || config.FullSyncSizeMbLimit != nil {
+		t.Fatalf("unexpected full sync limits: %v", config)
+	}
+	if config.GetRecentSyncDaysLimit() != 3 {
+		t.Fatalf("expected 3 recent days, got %d", config.GetRecentSyncDaysLimit())
+	}
+
+	if store.DeviceProps.GetRequireFullSync() {
+		t.Fatal("the global device props must not change")
+	}
+}
@@ -0,0 +1,183 @@
+package whatsmiau
+
+import (
+	"context"
+	"time"
+
+	"github.com/verbeux-ai/whatsmiau/env"
+	"github.com/verbeux-ai/whatsmiau/models"
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	"go.uber.org/zap"
+)
+
+// handleAlwaysOnline marks the instance as available once connected, which
+// WhatsApp forgets on every reconnect.
+func (s *Whatsmiau) handleAlwaysOnline(id string, instance *models.Instance) {
+	if !instance.AlwaysOnline {
+		return
+	}
+
+	if client, ok := s.clients.Load(id); ok {
+		s.sendAvailable(id, client)
+	}
+}
+
+// keepOnline re-asserts the available presence of the AlwaysOnline instances
+// every ALWAYS_ONLINE_INTERVAL. Instances are read through the cache, so
+// turning the setting on or off is followed without a restart; an instance that
+// was kept online and no longer is goes unavailable.
+func (s *Whatsmiau) keepOnline() {
+	if env.Env.AlwaysOnlineInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(env.Env.AlwaysOnlineInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.clients.Range(func(id string, client *whatsmeow.Client) bool {
+			if !client.IsConnected() || !client.IsLoggedIn() {
+				return true
+			}
+
+			instance, err := s.lookupInstance(id)
+			if err != nil {
+				zap.L().Warn("failed to get instance to keep online", zap.String("instance", id), zap.Error(err))
+				return true
+			}
+
+			if instance != nil && instance.AlwaysOnline {
+				s.sendAvailable(id, client)
+			} else if _, online := s.keptOnline.Load(id); online {
+				s.sendUnavailable(id, client)
+			}
+			return true
+		})
+	}
+}
+
+// sendAvailable marks the instance online and remembers it was kept online.
+func (s *Whatsmiau) sendAvailable(id string, client *whatsmeow.Client) {
+	if s.sendPresence(id, client, types.PresenceAvailable) {
+		s.keptOnline.Store(id, struct{}{})
+	}
+}
+
+// sendUnavailable ends the presence sendAvailable kept.
+func (s *Whatsmiau) sendUnavailable(id string, client *whatsmeow.Client) {
+	if s.sendPresence(id, client, types.PresenceUnavailable) {
+		s.keptOnline.Delete(id)
+	}
+}
+
+func (s *Whatsmiau) sendPresence(id string, client *whatsmeow.Client, presence types.Presence) bool {
+	ctx, c := context.WithTimeout(context.Background(), time.Second*10)
+	defer c()
+
+	if err := client.SendPresence(ctx, presence); err != nil {
+		zap.L().Warn("failed to send presence", zap.String("instance", id), zap.String("presence", string(presence)), zap.Error(err))
+		return false
+	}
+
+	return true
+}
+
+// handleAutoRead marks an incoming message read per ReadMessages, or a status
+// post viewed per ReadStatus. It runs after the message was emitted.
+func (s *Whatsmiau) handleAutoRead(id string, instance *models.Instance, e *events.Message) {
+	if !autoReadEnabled(instance, e) {
+		return
+	}
+
+	client, ok := s.clients.Load(id)
+	if !ok {
+		return
+	}
+
+	ctx, c := context.WithTimeout(context.Background(), time.Second*10)
+	defer c()
+
+	if err := client.MarkRead(ctx, []types.MessageID{e.Info.ID}, e.Info.Timestamp, e.Info.Chat, e.Info.Sender); err != nil {
+		zap.L().Warn("failed to mark message read", zap.String("instance", id), zap.String("message", e.Info.ID), zap.Error(err))
+	}
+}
+
+func autoReadEnabled(instance *models.Instance, e *events.Message) bool {
+	if e.Info.IsFromMe || e.Message == nil {
+		return false
+	}
+
+	// Protocol messages (revokes, edits, key shares) and reactions are not
+	// shown as messages, so they are never read
+	if e.Message.GetProtocolMessage() != nil || e.Message.GetReactionMessage() != nil {
+		return false
+	}
+
+	if e.Info.Chat == types.StatusBroadcastJID {
+		return instance.ReadStatus
+	}
+	if e.Info.Chat.Server == types.BroadcastServer {
+		return false
+	}
+
+	return instance.ReadMessages
+}
+
+// settingsChannel tells every process that the settings of an instance
+// changed, so the one running its client follows them.
+const settingsChannel = "whatsmiau_instance_settings"
+
+// SettingsUpdated makes the running client follow new instance settings right
+// away, on whichever process runs it: the cached instance is dropped so the
+// next event reads them, and AlwaysOnline is applied without waiting for
+// ALWAYS_ONLINE_INTERVAL.
+func (s *Whatsmiau) SettingsUpdated(instance *models.Instance) {
+	ctx, c := context.WithTimeout(context.Background(), time.Second*5)
+	defer c()
+
+	if err := s.redis.Publish(ctx, settingsChannel, instance.ID).Err(); err != nil {
+		zap.L().Error("failed to publish settings update", zap.String("instance", instance.ID), zap.Error(err))
+		s.applySettings(instance)
+	}
+}
+
+// watchSettings applies the settings updates published by any process.
+func (s *Whatsmiau) watchSettings() {
+	sub := s.redis.Subscribe(context.Background(), settingsChannel)
+	defer sub.Close()
+
+	for msg := range sub.Channel() {
+		s.invalidateInstance(msg.Payload)
+		if _, ok := s.clients.Load(msg.Payload); !ok {
+			continue
+		}
+
+		instance, err := s.lookupInstance(msg.Payload)
+		if err != nil {
+			zap.L().Warn("failed to get instance to apply settings", zap.String("instance", msg.Payload), zap.Error(err))
+			continue
+		}
+		if instance != nil {
+			s.applySettings(instance)
+		}
+	}
+}
+
+// applySettings drops the cached instance and sends the presence AlwaysOnline
+// asks for.
+func (s *Whatsmiau) applySettings(instance *models.Instance) {
+	s.invalidateInstance(instance.ID)
+
+	client, ok := s.clients.Load(instance.ID)
+	if !ok || !client.IsConnected() || !client.IsLoggedIn() {
+		return
+	}
+
+	if instance.AlwaysOnline {
+		go s.sendAvailable(instance.ID, client)
+	} else if _, online := s.keptOnline.Load(instance.ID); online {
+		go s.sendUnavailable(instance.ID, client)
+	}
+}
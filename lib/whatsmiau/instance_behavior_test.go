@@ -0,0 +1,42 @@
+package whatsmiau
+
+import (
+	"testing"
+
+	"github.com/verbeux-ai/whatsmiau/models"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestAutoReadEnabled(t *testing.T) {
+	chat := types.NewJID("5511999999999", types.DefaultUserServer)
+	text := &waE2E.Message{Conversation: proto.String("hi")}
+	message := func(chat types.JID, fromMe bool, msg *waE2E.Message) *events.Message {
+		return &events.Message{
+			Info:    types.MessageInfo{MessageSource: types.MessageSource{Chat: chat, IsFromMe: fromMe}},
+			Message: msg,
+		}
+	}
+
+	cases := []struct {
+		name     string
+		instance models.Instance
+		evt      *events.Message
+		expected bool
+	}{
+		{"disabled", models.Instance{}, message(chat, false, text), false},
+		{"read messages", models.Instance{ReadMessages: true}, message(chat, false, text), true},
+		{"from me", models.Instance{ReadMessages: true}, message(chat, true, text), false},
+		{"status without read status", models.Instance{ReadMessages: true}, message(types.StatusBroadcastJID, false, text), false},
+		{"read status", models.Instance{ReadStatus: true}, message(types.StatusBroadcastJID, false, text), true},
+		{"reaction", models.Instance{ReadMessages: true}, message(chat, false, &waE2E.Message{ReactionMessage: &waE2E.ReactionMessage{}}), false},
+	}
+
+	for _, tc := range cases {
+		if got := autoReadEnabled(&tc.instance, tc.evt); got != tc.expected {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.expected, got)
+		}
+	}
+}
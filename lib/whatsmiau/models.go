@@ -1,6 +1,7 @@
 package whatsmiau
 
 import (
+	"strings"
 	"time"
 
 	"github.com/emersion/go-vcard"
@@ -14,8 +15,72 @@ const (
 	WookContactsUpsert   Wook = "contacts.upsert"
 	WookConnectionUpdate Wook = "connection.update"
 	WookMessagesDelete   Wook = "messages.delete"
+	WookCall             Wook = "call"
+	WookPresenceUpdate   Wook = "presence.update"
+	WookMessagesEdited   Wook = "messages.edited"
+	WookGroupsUpsert     Wook = "groups.upsert"
+	WookGroupsUpdate     Wook = "groups.update"
+	// WookGroupParticipantsUpdate keeps the dash of Evolution API; its config
+	// name is GROUP_PARTICIPANTS_UPDATE.
+	WookGroupParticipantsUpdate Wook = "group-participants.update"
+	WookMessagesSet             Wook = "messages.set"
+	WookChatsSet                Wook = "chats.set"
+	WookHistorySyncProgress     Wook = "history-sync.progress"
+
+	// Application events, delivered to the global webhook only
+	WookInstanceCreate Wook = "instance.create"
+	WookInstanceDelete Wook = "instance.delete"
+	WookLogoutInstance Wook = "logout.instance"
 )
 
+// allWooks is every event the emitter produces; the global webhook receives
+// all of them when no events are configured.
+var allWooks = []Wook{
+	WookMessagesUpsert,
+	WookMessagesUpdate,
+	WookContactsUpsert,
+	WookConnectionUpdate,
+	WookMessagesDelete,
+	WookCall,
+	WookPresenceUpdate,
+	WookMessagesEdited,
+	WookGroupsUpsert,
+	WookGroupsUpdate,
+	WookGroupParticipantsUpdate,
+	WookMessagesSet,
+	WookChatsSet,
+	WookHistorySyncProgress,
+	WookInstanceCreate,
+	WookInstanceDelete,
+	WookLogoutInstance,
+}
+
+var configNameReplacer = strings.NewReplacer(".", "_", "-", "_")
+
+// ConfigName is how the event is referred to in webhook configs (Events,
+// EventUrls), e.g. MESSAGES_UPSERT or GROUP_PARTICIPANTS_UPDATE.
+func (w Wook) ConfigName() string {
+	return strings.ToUpper(configNameReplacer.Replace(string(w)))
+}
+
+// ParseWook accepts an event by its config (MESSAGES_UPSERT) or payload
+// (messages.upsert) name.
+func ParseWook(name string) (Wook, bool) {
+	for _, wook := range allWooks {
+		if string(wook) == name || wook.ConfigName() == strings.ToUpper(name) {
+			return wook, true
+		}
+	}
+
+	return "", false
+}
+
+// Path is the URL suffix used when webhooks are routed by event, following
+// Evolution API, e.g. messages-upsert.
+func (w Wook) Path() string {
+	return strings.ReplaceAll(string(w), ".", "-")
+}
+
 type WookEvent[data any] struct {
 	Instance    string    `json:"instance,omitempty"`
 	Data        *data     `json:"data,omitempty"`
@@ -27,6 +92,30 @@ type WookEvent[data any] struct {
 	Event       Wook      `json:"event,omitempty"`
 }
 
+// wookPayload is implemented by every WookEvent so the emitter can tell which
+// instance and event a queued body belongs to.
+type wookPayload interface {
+	instanceID() string
+	eventType() Wook
+	chat() wookChat
+	withoutBase64() wookPayload
+}
+
+func (e *WookEvent[data]) instanceID() string {
+	return e.Instance
+}
+
+func (e *WookEvent[data]) eventType() Wook {
+	return e.Event
+}
+
+// WookInstanceData is the payload of the application events.
+type WookInstanceData struct {
+	InstanceName string `json:"instanceName"`
+	Wuid         string `json:"wuid,omitempty"`
+	Reason       string `json:"reason,omitempty"`
+}
+
 type WookMessageData struct {
 	Key              *WookKey                `json:"key,omitempty"`
 	PushName         string                  `json:"pushName,omitempty"`
@@ -251,6 +340,7 @@ type WookMessageUpdateStatus string
 const (
 	MessageStatusDeliveryAck WookMessageUpdateStatus = "DELIVERY_ACK"
 	MessageStatusRead        WookMessageUpdateStatus = "READ"
+	MessageStatusPollVote    WookMessageUpdateStatus = "POLL_VOTE"
 )
 
 type WookMessageDeleteData struct {
@@ -262,6 +352,18 @@ type WookMessageDeleteData struct {
 	InstanceId  string `json:"instanceId,omitempty"`
 }
 
+// WookMessageEditData is an edit of an earlier message: Key is the original
+// message and Message its new content.
+type WookMessageEditData struct {
+	Key              *WookKey        `json:"key,omitempty"`
+	EditId           string          `json:"editId,omitempty"` // id of the edit itself
+	PushName         string          `json:"pushName,omitempty"`
+	Message          *WookMessageRaw `json:"message,omitempty"`
+	MessageType      string          `json:"messageType,omitempty"`
+	MessageTimestamp int             `json:"messageTimestamp,omitempty"` // of the edit
+	InstanceId       string          `json:"instanceId,omitempty"`
+}
+
 type WookMessageUpdateData struct {
 	MessageId      string                  `json:"messageId,omitempty"`
 	KeyId          string                  `json:"keyId,omitempty"`
@@ -271,9 +373,19 @@ type WookMessageUpdateData struct {
 	Participant    string                  `json:"participant,omitempty"`
 	ParticipantLid string                  `json:"participantLid,omitempty"`
 	Status         WookMessageUpdateStatus `json:"status,omitempty"`
+	PollVote       *WookPollVote           `json:"pollVote,omitempty"`
 	InstanceId     string                  `json:"instanceId,omitempty"`
 }
 
+// WookPollVote is a decrypted poll vote; an empty SelectedOptions means the
+// voter removed their vote.
+type WookPollVote struct {
+	PollName        string   `json:"pollName"`
+	SelectedOptions []string `json:"selectedOptions"`
+	Voter           string   `json:"voter"`
+	VoterLid        string   `json:"voterLid,omitempty"`
+}
+
 type WookContact struct {
 	RemoteJid     string `json:"remoteJid,omitempty"`
 	RemoteLid     string `json:"remoteLid"`
@@ -293,3 +405,135 @@ type WookConnectionUpdateData struct {
 	State             string `json:"state"`
 	StatusReason      int    `json:"statusReason,omitempty"`
 }
+
+type WookCallStatus string
+
+const (
+	CallStatusOffer     WookCallStatus = "offer"
+	CallStatusAccept    WookCallStatus = "accept"
+	CallStatusReject    WookCallStatus = "reject"
+	CallStatusTerminate WookCallStatus = "terminate"
+)
+
+type WookCallData struct {
+	Id       string         `json:"id"`
+	From     string         `json:"from"`
+	FromLid  string         `json:"fromLid,omitempty"`
+	ChatId   string         `json:"chatId"` // group of group calls, the caller otherwise
+	IsGroup  bool           `json:"isGroup"`
+	GroupJid string         `json:"groupJid,omitempty"`
+	IsVideo  bool           `json:"isVideo"`
+	Status   WookCallStatus `json:"status"`
+	Reason   string         `json:"reason,omitempty"` // terminate only
+	// Rejected is set on offers rejected because of the instance RejectCall.
+	Rejected   bool      `json:"rejected,omitempty"`
+	Date       time.Time `json:"date"`
+	InstanceId string    `json:"instanceId,omitempty"`
+}
+
+// WookHistorySync tells which part of a history sync a messages.set or
+// chats.set page belongs to. Page counts from 1 within the chunk, or within
+// the chat for messages.set, and LastPage marks its end.
+type WookHistorySync struct {
+	SyncType   string `json:"syncType"`
+	ChunkOrder uint32 `json:"chunkOrder"`
+	Progress   uint32 `json:"progress"`
+	Page       int    `json:"page"`
+	LastPage   bool   `json:"lastPage"`
+}
+
+// WookMessagesSetData is a page of the past messages of one chat.
+type WookMessagesSetData struct {
+	WookHistorySync
+	RemoteJid  string            `json:"remoteJid"`
+	RemoteLid  string            `json:"remoteLid,omitempty"`
+	Messages   []WookMessageData `json:"messages"`
+	InstanceId string            `json:"instanceId"`
+}
+
+// WookHistorySyncProgressData is sent for every history sync chunk. Progress
+// is the percentage WhatsApp reports for initial and full syncs; Completed
+// is set once it reaches 100.
+type WookHistorySyncProgressData struct {
+	SyncType      string `json:"syncType"`
+	ChunkOrder    uint32 `json:"chunkOrder"`
+	Progress      uint32 `json:"progress"`
+	Completed     bool   `json:"completed"`
+	Conversations int    `json:"conversations"`
+	Messages      int    `json:"messages"`
+	InstanceId    string `json:"instanceId"`
+}
+
+type WookChat struct {
+	RemoteJid             string `json:"remoteJid"`
+	RemoteLid             string `json:"remoteLid,omitempty"`
+	Name                  string `json:"name,omitempty"`
+	UnreadCount           uint32 `json:"unreadCount"`
+	ConversationTimestamp uint64 `json:"conversationTimestamp,omitempty"`
+	Archived              bool   `json:"archived"`
+	Pinned                bool   `json:"pinned"`
+	ReadOnly              bool   `json:"readOnly"`
+	EphemeralExpiration   uint32 `json:"ephemeralExpiration,omitempty"`
+	MuteEndTime           uint64 `json:"muteEndTime,omitempty"`
+}
+
+type WookChatsSetData struct {
+	WookHistorySync
+	Chats      []WookChat `json:"chats"`
+	InstanceId string     `json:"instanceId"`
+}
+
+// WookPresenceData follows Evolution API: presences are keyed by the
+// participant, which is the chat itself outside of groups.
+type WookPresenceData struct {
+	Id        string                  `json:"id"`
+	Presences map[string]WookPresence `json:"presences"`
+}
+
+type WookPresence struct {
+	// LastKnownPresence is unavailable, available, composing, recording or
+	// paused.
+	LastKnownPresence string `json:"lastKnownPresence"`
+	LastSeen          int64  `json:"lastSeen,omitempty"` // unix seconds, when not hidden
+}
+
+// WookGroupsUpsertData is the metadata of the groups the instance joined.
+type WookGroupsUpsertData []GroupInfoResponse
+
+// WookGroupsUpdateData carries only the fields of a group that changed.
+type WookGroupsUpdateData []WookGroupUpdate
+
+type WookGroupUpdate struct {
+	Id               string  `json:"id"`
+	Subject          *string `json:"subject,omitempty"`
+	Desc             *string `json:"desc,omitempty"`
+	DescId           string  `json:"descId,omitempty"`
+	Restrict         *bool   `json:"restrict,omitempty"`
+	Announce         *bool   `json:"announce,omitempty"`
+	Ephemeral        *uint32 `json:"ephemeral,omitempty"`
+	JoinApprovalMode *bool   `json:"joinApprovalMode,omitempty"`
+	InviteLink       string  `json:"inviteLink,omitempty"`
+	Deleted          bool    `json:"deleted,omitempty"`
+	Suspended        *bool   `json:"suspended,omitempty"`
+	Author           string  `json:"author,omitempty"`
+	AuthorLid        string  `json:"authorLid,omitempty"`
+	Timestamp        int64   `json:"timestamp,omitempty"`
+}
+
+type WookGroupParticipantsAction string
+
+const (
+	GroupParticipantsAdd     WookGroupParticipantsAction = "add"
+	GroupParticipantsRemove  WookGroupParticipantsAction = "remove"
+	GroupParticipantsPromote WookGroupParticipantsAction = "promote"
+	GroupParticipantsDemote  WookGroupParticipantsAction = "demote"
+)
+
+type WookGroupParticipantsData struct {
+	Id           string                      `json:"id"`
+	Action       WookGroupParticipantsAction `json:"action"`
+	Participants []string                    `json:"participants"`
+	Author       string                      `json:"author,omitempty"`
+	AuthorLid    string                      `json:"authorLid,omitempty"`
+	InstanceId   string                      `json:"instanceId,omitempty"`
+}
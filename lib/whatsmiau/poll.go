@@ -0,0 +1,184 @@
+package whatsmiau
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"github.com/verbeux-ai/whatsmiau/models"
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	"go.uber.org/zap"
+)
+
+// handlePollEvent records incoming polls and decrypts the votes on them. It
+// runs whether or not anything is subscribed, so the tally stays complete,
+// and reports if the message was a vote that got emitted as messages.update.
+func (s *Whatsmiau) handlePollEvent(id string, instance *models.Instance, e *events.Message, eventMap map[string]bool) bool {
+	if e.Message == nil {
+		return false
+	}
+
+	ctx, c := context.WithTimeout(context.Background(), time.Second*5)
+	defer c()
+
+	if creation := pollCreation(e.Message); creation != nil {
+		s.savePoll(ctx, id, e.Info.ID, e.Info.Chat, e.Info.Sender, creation, e.Info.Timestamp)
+		return false
+	}
+
+	update := e.Message.GetPollUpdateMessage()
+	if update == nil {
+		return false
+	}
+
+	client, ok := s.clients.Load(id)
+	if !ok {
+		return false
+	}
+
+	pollID := update.GetPollCreationMessageKey().GetID()
+	poll, err := s.polls.Get(ctx, id, pollID)
+	if err != nil {
+		zap.L().Debug("vote on unknown poll", zap.String("instance", id), zap.String("poll", pollID), zap.Error(err))
+		return false
+	}
+
+	vote, err := client.DecryptPollVote(ctx, e)
+	if err != nil {
+		zap.L().Warn("failed to decrypt poll vote", zap.String("instance", id), zap.String("poll", pollID), zap.Error(err))
+		return false
+	}
+
+	voter, voterLid := s.pollVoter(ctx, id, e.Info.MessageSource)
+	selected := pollOptionNames(poll.Options, vote.GetSelectedOptions())
+	if err := s.polls.Vote(ctx, id, pollID, &models.PollVote{
+		Voter:    voter,
+		VoterLid: voterLid,
+		Options:  selected,
+		At:       e.Info.Timestamp,
+	}); err != nil {
+		zap.L().Error("failed to save poll vote", zap.String("instance", id), zap.String("poll", pollID), zap.Error(err))
+	}
+
+	if !eventMap["MESSAGES_UPDATE"] || canIgnoreGroup(e, instance) {
+		return true
+	}
+
+	remoteJid, remoteLid := s.GetJidLid(ctx, id, e.Info.Chat)
+	data := &WookMessageUpdateData{
+		MessageId:      e.Info.ID,
+		KeyId:          pollID,
+		RemoteJid:      remoteJid,
+		RemoteLid:      remoteLid,
+		FromMe:         update.GetPollCreationMessageKey().GetFromMe(),
+		Participant:    voter,
+		ParticipantLid: voterLid,
+		Status:         MessageStatusPollVote,
+		PollVote: &WookPollVote{
+			PollName:        poll.Name,
+			SelectedOptions: selected,
+			Voter:           voter,
+			VoterLid:        voterLid,
+		},
+		InstanceId: instance.ID,
+	}
+
+	zap.L().Debug("poll vote event", zap.String("instance", id), zap.Any("data", data))
+	s.emit(&WookEvent[WookMessageUpdateData]{
+		Instance: instance.ID,
+		Data:     data,
+		DateTime: e.Info.Timestamp,
+		Event:    WookMessagesUpdate,
+	}, instance)
+
+	return true
+}
+
+// savePoll keeps the options of a poll, votes only carry their hashes.
+func (s *Whatsmiau) savePoll(ctx context.Context, id, pollID string, chat, creator types.JID, creation *waE2E.PollCreationMessage, createdAt time.Time) {
+	options := make([]string, 0, len(creation.GetOptions()))
+	for _, option := range creation.GetOptions() {
+		options = append(options, option.GetOptionName())
+	}
+
+	remoteJid, _ := s.GetJidLid(ctx, id, chat)
+	poll := &models.Poll{
+		ID:              pollID,
+		InstanceID:      id,
+		RemoteJid:       remoteJid,
+		Name:            creation.GetName(),
+		Options:         options,
+		SelectableCount: int(creation.GetSelectableOptionsCount()),
+		CreatedAt:       createdAt,
+	}
+	if !creator.IsEmpty() {
+		poll.Creator, _ = s.GetJidLid(ctx, id, creator.ToNonAD())
+	}
+
+	if err := s.polls.Save(ctx, poll); err != nil {
+		zap.L().Error("failed to save poll", zap.String("instance", id), zap.String("poll", pollID), zap.Error(err))
+	}
+}
+
+// pollVoter resolves the voter of a vote with its LID, which keys the vote. The
+// LID comes from the alternative address of the sender when the store does not
+// know it yet.
+func (s *Whatsmiau) pollVoter(ctx context.Context, id string, source types.MessageSource) (string, string) {
+	voter, voterLid := s.GetJidLid(ctx, id, source.Sender.ToNonAD())
+	if voterLid == "" && source.SenderAlt.Server == types.HiddenUserServer {
+		voterLid = source.SenderAlt.ToNonAD().String()
+	}
+
+	return voter, voterLid
+}
+
+// PollResults tallies the current votes of a poll sent or received by the
+// instance.
+func (s *Whatsmiau) PollResults(ctx context.Context, instanceID, pollID string) (*models.PollResults, error) {
+	poll, err := s.polls.Get(ctx, instanceID, pollID)
+	if err != nil {
+		return nil, err
+	}
+
+	votes, err := s.polls.Votes(ctx, instanceID, pollID)
+	if err != nil {
+		return nil, err
+	}
+
+	return poll.Results(votes), nil
+}
+
+func pollCreation(m *waE2E.Message) *waE2E.PollCreationMessage {
+	for _, poll := range []*waE2E.PollCreationMessage{
+		m.GetPollCreationMessage(),
+		m.GetPollCreationMessageV2(),
+		m.GetPollCreationMessageV3(),
+		m.GetPollCreationMessageV5(),
+		m.GetPollCreationMessageV6(),
+	} {
+		if poll != nil {
+			return poll
+		}
+	}
+
+	return nil
+}
+
+// pollOptionNames maps the option hashes of a vote back to their names.
+func pollOptionNames(options []string, hashes [][]byte) []string {
+	optionHashes := whatsmeow.HashPollOptions(options)
+	names := make([]string, 0, len(hashes))
+	for _, hash := range hashes {
+		for i, optionHash := range optionHashes {
+			if bytes.Equal(hash, optionHash) {
+				names = append(names, options[i])
+				break
+			}
+		}
+	}
+
+	return names
+}
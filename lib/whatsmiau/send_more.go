@@ -307,6 +307,8 @@ func (s *Whatsmiau) SendPoll(ctx context.Context, data *SendPollRequest) (*SendP
 		return nil, err
 	}
 
+	s.savePoll(ctx, data.InstanceID, res.ID, resolved, client.Store.GetJID(), pollMsg.GetPollCreationMessage(), res.Timestamp)
+
 	return &SendPollResponse{ID: res.ID, CreatedAt: res.Timestamp}, nil
 }
 
@@ -0,0 +1,118 @@
+package whatsmiau
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/puzpuzpuz/xsync/v4"
+	"github.com/verbeux-ai/whatsmiau/models"
+	"golang.org/x/net/context"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+// setWebhookHeaders applies the configured custom headers and authentication to
+// an outgoing delivery. target identifies the webhook, see webhookTargetKey.
+func (s *Whatsmiau) setWebhookHeaders(req *http.Request, target string, webhook *models.InstanceWebhook) error {
+	if webhook == nil {
+		return nil
+	}
+
+	for k, v := range webhook.Headers {
+		req.Header.Set(k, v)
+	}
+
+	auth := webhook.Auth
+	if auth == nil {
+		return nil
+	}
+
+	switch auth.Type {
+	case models.WebhookAuthBearer:
+		req.Header.Set("Authorization", "Bearer "+auth.Token)
+	case models.WebhookAuthBasic:
+		req.SetBasicAuth(auth.Username, auth.Password)
+	case models.WebhookAuthOAuth2:
+		token, err := s.webhookTokenSource(target, auth).Token()
+		if err != nil {
+			return fmt.Errorf("failed to get oauth2 token: %w", err)
+		}
+		token.SetAuthHeader(req)
+	case "":
+	default:
+		return fmt.Errorf("unknown webhook auth type %q", auth.Type)
+	}
+
+	return nil
+}
+
+// webhookToken is the token source of a webhook, kept with the credentials it
+// was made from so a change of auth replaces it.
+type webhookToken struct {
+	key    string
+	source oauth2.TokenSource
+}
+
+// webhookTokenSource returns the cached token source of the webhook, so tokens
+// are reused until they expire instead of fetched per delivery.
+func (s *Whatsmiau) webhookTokenSource(target string, auth *models.InstanceWebhookAuth) oauth2.TokenSource {
+	key := webhookTokenKey(auth)
+	token, _ := s.webhookTokens.Compute(target, func(token webhookToken, loaded bool) (webhookToken, xsync.ComputeOp) {
+		if loaded && token.key == key {
+			return token, xsync.CancelOp
+		}
+
+		params := url.Values{}
+		for k, v := range auth.EndpointParams {
+			params.Set(k, v)
+		}
+
+		config := &clientcredentials.Config{
+			ClientID:       auth.ClientID,
+			ClientSecret:   auth.ClientSecret,
+			TokenURL:       auth.TokenURL,
+			Scopes:         auth.Scopes,
+			EndpointParams: params,
+		}
+
+		ctx := context.WithValue(context.Background(), oauth2.HTTPClient, s.httpClient)
+		return webhookToken{key: key, source: config.TokenSource(ctx)}, xsync.UpdateOp
+	})
+
+	return token.source
+}
+
+// invalidateWebhookToken drops the cached token so the next delivery fetches a
+// fresh one, used when the receiver rejects the current token.
+func (s *Whatsmiau) invalidateWebhookToken(target string) {
+	s.webhookTokens.Delete(target)
+}
+
+// forgetWebhookTokens drops the token sources of an instance.
+func (s *Whatsmiau) forgetWebhookTokens(instanceID string) {
+	s.webhookTokens.Range(func(target string, _ webhookToken) bool {
+		if strings.HasPrefix(target, instanceID+"|") {
+			s.webhookTokens.Delete(target)
+		}
+		return true
+	})
+}
+
+func webhookTokenKey(auth *models.InstanceWebhookAuth) string {
+	params := make([]string, 0, len(auth.EndpointParams))
+	for k, v := range auth.EndpointParams {
+		params = append(params, k+"="+v)
+	}
+	sort.Strings(params)
+
+	return strings.Join([]string{
+		auth.TokenURL,
+		auth.ClientID,
+		auth.ClientSecret,
+		strings.Join(auth.Scopes, " "),
+		strings.Join(params, "&"),
+	}, "\n")
+}
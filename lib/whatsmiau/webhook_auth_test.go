@@ -0,0 +1,92 @@
+package whatsmiau
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/puzpuzpuz/xsync/v4"
+	"github.com/verbeux-ai/whatsmiau/models"
+)
+
+func newAuthTestMiau() *Whatsmiau {
+	return &Whatsmiau{
+		httpClient:    http.DefaultClient,
+		webhookTokens: xsync.NewMap[string, webhookToken](),
+	}
+}
+
+func TestSetWebhookHeadersAppliesHeadersAndBasicAuth(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "http://receiver", nil)
+	err := newAuthTestMiau().setWebhookHeaders(req, "acme|", &models.InstanceWebhook{
+		Headers: map[string]string{"X-Tenant": "acme"},
+		Auth:    &models.InstanceWebhookAuth{Type: models.WebhookAuthBasic, Username: "user", Password: "pass"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := req.Header.Get("X-Tenant"); got != "acme" {
+		t.Errorf("X-Tenant = %q, want acme", got)
+	}
+	if user, pass, ok := req.BasicAuth(); !ok || user != "user" || pass != "pass" {
+		t.Errorf("unexpected basic auth %q %q %v", user, pass, ok)
+	}
+}
+
+func TestSetWebhookHeadersCachesOAuth2Token(t *testing.T) {
+	var requests atomic.Int32
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"abc","token_type":"bearer","expires_in":3600}`))
+	}))
+	defer tokenServer.Close()
+
+	miau := newAuthTestMiau()
+	webhook := &models.InstanceWebhook{
+		Auth: &models.InstanceWebhookAuth{
+			Type:         models.WebhookAuthOAuth2,
+			TokenURL:     tokenServer.URL,
+			ClientID:     "id",
+			ClientSecret: "secret",
+		},
+	}
+
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest(http.MethodPost, "http://receiver", nil)
+		if err := miau.setWebhookHeaders(req, "acme|", webhook); err != nil {
+			t.Fatal(err)
+		}
+		if got := req.Header.Get("Authorization"); got != "Bearer abc" {
+			t.Fatalf("Authorization = %q, want Bearer abc", got)
+		}
+	}
+
+	if got := requests.Load(); got != 1 {
+		t.Errorf("expected a single token request, got %d", got)
+	}
+
+	miau.invalidateWebhookToken("acme|")
+	req := httptest.NewRequest(http.MethodPost, "http://receiver", nil)
+	if err := miau.setWebhookHeaders(req, "acme|", webhook); err != nil {
+		t.Fatal(err)
+	}
+	if got := requests.Load(); got != 2 {
+		t.Errorf("expected a new token request after invalidation, got %d", got)
+	}
+
+	// new credentials replace the cached token source
+	webhook.Auth.ClientSecret = "rotated"
+	req = httptest.NewRequest(http.MethodPost, "http://receiver", nil)
+	if err := miau.setWebhookHeaders(req, "acme|", webhook); err != nil {
+		t.Fatal(err)
+	}
+	if got := requests.Load(); got != 3 {
+		t.Errorf("expected a new token request after the auth changed, got %d", got)
+	}
+	if got := miau.webhookTokens.Size(); got != 1 {
+		t.Errorf("expected one cached token source, got %d", got)
+	}
+}
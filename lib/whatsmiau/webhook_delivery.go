@@ -0,0 +1,46 @@
+package whatsmiau
+
+import (
+	"time"
+
+	"github.com/verbeux-ai/whatsmiau/models"
+	"go.uber.org/zap"
+	"golang.org/x/net/context"
+)
+
+// maxDeliveryError bounds the error or response snippet kept in the delivery
+// log.
+const maxDeliveryError = 512
+
+// recordDelivery adds an attempt to the delivery log of the instance.
+func (s *Whatsmiau) recordDelivery(event emitter, result emitResult, latency time.Duration) {
+	if event.instance == "" {
+		return
+	}
+
+	snippet := result.Error
+	if snippet == "" && !result.Success {
+		snippet = result.Response
+	}
+	if len(snippet) > maxDeliveryError {
+		snippet = snippet[:maxDeliveryError]
+	}
+
+	ctx, c := context.WithTimeout(context.Background(), time.Second*5)
+	defer c()
+
+	if err := s.deliveries.Record(ctx, &models.WebhookDelivery{
+		InstanceID:   event.instance,
+		Subscription: event.subscription,
+		Event:        string(event.event),
+		MessageID:    event.messageID,
+		Url:          event.url,
+		StatusCode:   result.StatusCode,
+		Success:      result.Success,
+		LatencyMs:    latency.Milliseconds(),
+		Attempt:      event.attempts,
+		Error:        snippet,
+	}); err != nil {
+		zap.L().Error("failed to record webhook delivery", zap.String("instance", event.instance), zap.Error(err))
+	}
+}
@@ -0,0 +1,93 @@
+package whatsmiau
+
+import (
+	"time"
+
+	"github.com/verbeux-ai/whatsmiau/env"
+	"github.com/verbeux-ai/whatsmiau/models"
+)
+
+// globalWebhookID marks emitter entries and dead letters of the global webhook
+// in place of a subscription id.
+const globalWebhookID = "global"
+
+// newGlobalWebhook builds the operator level webhook from the environment. It
+// is nil when GLOBAL_WEBHOOK_URL is not set.
+func newGlobalWebhook(e env.E) *models.InstanceWebhook {
+	if e.GlobalWebhookURL == "" {
+		return nil
+	}
+
+	return &models.InstanceWebhook{
+		Url:      e.GlobalWebhookURL,
+		ByEvents: &e.GlobalWebhookByEvents,
+		Base64:   &e.GlobalWebhookBase64,
+		Headers:  e.GlobalWebhookHeaders,
+		Events:   allEventsIfEmpty(e.GlobalWebhookEvents),
+		Secret:   e.GlobalWebhookSecret,
+	}
+}
+
+// allEventsIfEmpty expands an empty event filter of the operator level targets
+// to every event.
+func allEventsIfEmpty(events []string) []string {
+	if len(events) > 0 {
+		return events
+	}
+
+	for _, wook := range allWooks {
+		events = append(events, wook.ConfigName())
+	}
+
+	return events
+}
+
+// InstanceCreated notifies the global webhook, sink and stream that an
+// instance was created.
+func (s *Whatsmiau) InstanceCreated(instance *models.Instance) {
+	s.emitApplicationEvent(WookInstanceCreate, instance, "")
+}
+
+// InstanceDeleted notifies the global webhook, sink and stream that an
+// instance was deleted, and drops what was cached for it.
+func (s *Whatsmiau) InstanceDeleted(instance *models.Instance) {
+	s.forgetWebhookTemplates(instance.ID)
+	s.forgetWebhookTokens(instance.ID)
+	s.SinkUpdated(instance.ID)
+	s.emitApplicationEvent(WookInstanceDelete, instance, "")
+}
+
+// emitLoggedOut notifies the global webhook, sink and stream that the session
+// of an instance ended, either through the API or from the phone (reason).
+func (s *Whatsmiau) emitLoggedOut(id string, reason string) {
+	instance := s.getInstanceCached(id)
+	if instance == nil {
+		instance = &models.Instance{ID: id}
+	}
+
+	s.emitApplicationEvent(WookLogoutInstance, instance, reason)
+}
+
+func (s *Whatsmiau) emitApplicationEvent(event Wook, instance *models.Instance, reason string) {
+	body := &WookEvent[WookInstanceData]{
+		Instance: instance.ID,
+		Data: &WookInstanceData{
+			InstanceName: instance.ID,
+			Wuid:         instance.RemoteJID,
+			Reason:       reason,
+		},
+		DateTime: time.Now(),
+		Event:    event,
+	}
+
+	var targets []webhookTarget
+	if s.globalWebhook != nil {
+		targets = append(targets, webhookTarget{webhook: s.globalWebhook, global: true})
+	}
+	if s.globalSink != nil {
+		targets = append(targets, brokerTarget(s.globalSink, true))
+	}
+
+	s.emitTo(body, targets)
+	s.stream.publish(body, true)
+}
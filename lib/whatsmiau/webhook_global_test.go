@@ -0,0 +1,39 @@
+package whatsmiau
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/verbeux-ai/whatsmiau/env"
+)
+
+func TestNewGlobalWebhook(t *testing.T) {
+	if webhook := newGlobalWebhook(env.E{}); webhook != nil {
+		t.Fatalf("expected no global webhook without url, got %+v", webhook)
+	}
+
+	webhook := newGlobalWebhook(env.E{GlobalWebhookURL: "https://example.com/hook"})
+	if webhook == nil {
+		t.Fatal("expected global webhook")
+	}
+	for _, wook := range allWooks {
+		if !slices.Contains(webhook.Events, wook.ConfigName()) {
+			t.Errorf("expected %s in default events", wook.ConfigName())
+		}
+	}
+
+	webhook = newGlobalWebhook(env.E{
+		GlobalWebhookURL:    "https://example.com/hook",
+		GlobalWebhookEvents: []string{"INSTANCE_CREATE"},
+	})
+	target := webhookTarget{webhook: webhook, global: true}
+	if !target.accepts(WookInstanceCreate, wookChat{}) {
+		t.Error("expected INSTANCE_CREATE to be accepted")
+	}
+	if target.accepts(WookMessagesUpsert, wookChat{jid: "5511999999999@s.whatsapp.net", ok: true}) {
+		t.Error("expected MESSAGES_UPSERT to be filtered out")
+	}
+	if target.subscriptionID() != globalWebhookID {
+		t.Errorf("subscriptionID = %q, want %q", target.subscriptionID(), globalWebhookID)
+	}
+}
@@ -0,0 +1,32 @@
+package whatsmiau
+
+import (
+	"bytes"
+	"compress/gzip"
+
+	"github.com/verbeux-ai/whatsmiau/env"
+	"github.com/verbeux-ai/whatsmiau/models"
+	"go.uber.org/zap"
+)
+
+// compressWebhookBody gzips the body for webhooks that asked for it once it
+// reaches WEBHOOK_GZIP_MIN_SIZE. It reports whether the body was compressed.
+func compressWebhookBody(data []byte, webhook *models.InstanceWebhook) ([]byte, bool) {
+	if webhook == nil || webhook.Gzip == nil || !*webhook.Gzip || len(data) < env.Env.WebhookGzipMinSize {
+		return data, false
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(len(data) / 4)
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		zap.L().Error("failed to compress webhook body", zap.Error(err))
+		return data, false
+	}
+	if err := w.Close(); err != nil {
+		zap.L().Error("failed to compress webhook body", zap.Error(err))
+		return data, false
+	}
+
+	return buf.Bytes(), true
+}
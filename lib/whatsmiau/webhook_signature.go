@@ -0,0 +1,61 @@
+package whatsmiau
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/verbeux-ai/whatsmiau/models"
+)
+
+// Receivers verify a delivery by computing HMAC-SHA256 over
+// "<timestamp>.<raw body>" with their secret and comparing it with any of the
+// comma separated "sha256=<hex>" values of the signature header. Two values are
+// sent while a rotated secret is still inside its grace period.
+const (
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookSignatureHeader = "X-Webhook-Signature"
+)
+
+func signWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookSecrets returns the secrets that must sign a delivery made at now,
+// current one first.
+func webhookSecrets(webhook *models.InstanceWebhook, now time.Time) []string {
+	if webhook == nil || webhook.Secret == "" {
+		return nil
+	}
+
+	secrets := []string{webhook.Secret}
+	if webhook.PreviousSecret != "" && webhook.PreviousSecretExpiresAt != nil && now.Before(*webhook.PreviousSecretExpiresAt) {
+		secrets = append(secrets, webhook.PreviousSecret)
+	}
+
+	return secrets
+}
+
+func setWebhookSignature(header http.Header, webhook *models.InstanceWebhook, body []byte, now time.Time) {
+	secrets := webhookSecrets(webhook, now)
+	if len(secrets) == 0 {
+		return
+	}
+
+	timestamp := now.Unix()
+	signatures := make([]string, 0, len(secrets))
+	for _, secret := range secrets {
+		signatures = append(signatures, signWebhook(secret, timestamp, body))
+	}
+
+	header.Set(WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	header.Set(WebhookSignatureHeader, strings.Join(signatures, ","))
+}
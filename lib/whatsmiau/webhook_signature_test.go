@@ -0,0 +1,49 @@
+package whatsmiau
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/verbeux-ai/whatsmiau/models"
+)
+
+func TestSignWebhookMatchesKnownVector(t *testing.T) {
+	// echo -n '1700000000.{"event":"messages.upsert"}' | openssl dgst -sha256 -hmac secret
+	got := signWebhook("secret", 1700000000, []byte(`{"event":"messages.upsert"}`))
+	want := "sha256=93600f99bb5f64e51b5c2f60f0348b2600f5d8fb7d30f2efb4f3ebdb31b450df"
+	if got != want {
+		t.Errorf("signWebhook() = %s, want %s", got, want)
+	}
+}
+
+func TestSetWebhookSignatureDuringGracePeriod(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	expires := now.Add(time.Hour)
+	webhook := &models.InstanceWebhook{
+		Secret:                  "new",
+		PreviousSecret:          "old",
+		PreviousSecretExpiresAt: &expires,
+	}
+
+	header := http.Header{}
+	setWebhookSignature(header, webhook, []byte("{}"), now)
+	if got := len(strings.Split(header.Get(WebhookSignatureHeader), ",")); got != 2 {
+		t.Fatalf("expected 2 signatures inside grace period, got %d", got)
+	}
+
+	header = http.Header{}
+	setWebhookSignature(header, webhook, []byte("{}"), expires.Add(time.Second))
+	if got := header.Get(WebhookSignatureHeader); got != signWebhook("new", expires.Add(time.Second).Unix(), []byte("{}")) {
+		t.Errorf("expected only the current secret after grace period, got %s", got)
+	}
+}
+
+func TestSetWebhookSignatureWithoutSecret(t *testing.T) {
+	header := http.Header{}
+	setWebhookSignature(header, &models.InstanceWebhook{}, []byte("{}"), time.Now())
+	if header.Get(WebhookSignatureHeader) != "" || header.Get(WebhookTimestampHeader) != "" {
+		t.Errorf("expected no signature headers without a secret, got %v", header)
+	}
+}
@@ -0,0 +1,248 @@
+package whatsmiau
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/verbeux-ai/whatsmiau/models"
+)
+
+// webhookTarget is one destination the events of an instance fan out to: the
+// main webhook, one of its subscriptions, the global webhook or a broker sink.
+type webhookTarget struct {
+	subscription *models.WebhookSubscription // nil for the main and global webhooks
+	webhook      *models.InstanceWebhook
+	broker       *models.InstanceSink // set for NATS/AMQP sinks, nil for webhooks
+	global       bool
+}
+
+// webhookTargetKey identifies a webhook in the caches of compiled templates and
+// auth tokens: the main webhook of an instance, one of its subscriptions, or
+// the global webhook, which all instances share.
+func webhookTargetKey(instance, subscription string) string {
+	if subscription == globalWebhookID {
+		return globalWebhookID
+	}
+
+	return instance + "|" + subscription
+}
+
+func (t webhookTarget) subscriptionID() string {
+	if t.global {
+		return globalWebhookID
+	}
+
+	if t.subscription == nil {
+		return ""
+	}
+
+	return t.subscription.ID
+}
+
+func webhookEnabled(webhook *models.InstanceWebhook) bool {
+	return webhook.Enabled == nil || *webhook.Enabled
+}
+
+func base64Enabled(webhook *models.InstanceWebhook) bool {
+	return webhook.Base64 != nil && *webhook.Base64
+}
+
+func (s *Whatsmiau) webhookTargets(instance *models.Instance) []webhookTarget {
+	var targets []webhookTarget
+	if s.globalWebhook != nil {
+		targets = append(targets, webhookTarget{webhook: s.globalWebhook, global: true})
+	}
+
+	if webhookEnabled(&instance.Webhook) {
+		targets = append(targets, webhookTarget{webhook: &instance.Webhook})
+	}
+
+	for i := range instance.Subscriptions {
+		sub := &instance.Subscriptions[i]
+		if webhookEnabled(&sub.InstanceWebhook) {
+			targets = append(targets, webhookTarget{subscription: sub, webhook: &sub.InstanceWebhook})
+		}
+	}
+
+	if s.globalSink != nil {
+		targets = append(targets, brokerTarget(s.globalSink, true))
+	}
+	if instance.Sink != nil && instance.Sink.Type != "" {
+		if target := brokerTarget(instance.Sink, false); webhookEnabled(target.webhook) {
+			targets = append(targets, target)
+		}
+	}
+
+	return targets
+}
+
+// subscribedEvents merges the events of every enabled target and stream
+// consumer, so handlers only build the payloads somebody will receive.
+func (s *Whatsmiau) subscribedEvents(instance *models.Instance) map[string]bool {
+	eventMap := make(map[string]bool)
+	for _, target := range s.webhookTargets(instance) {
+		for _, event := range target.webhook.Events {
+			eventMap[event] = true
+		}
+	}
+	s.stream.subscribedEvents(instance.ID, eventMap)
+
+	return eventMap
+}
+
+// instanceWantsBase64 reports whether any target asked for inline media, in
+// which case it is downloaded once and stripped for the others.
+func (s *Whatsmiau) instanceWantsBase64(instance *models.Instance) bool {
+	for _, target := range s.webhookTargets(instance) {
+		if base64Enabled(target.webhook) {
+			return true
+		}
+	}
+
+	return s.stream.wantsBase64(instance.ID)
+}
+
+func (t webhookTarget) accepts(event Wook, chat wookChat) bool {
+	if !slices.Contains(t.webhook.Events, event.ConfigName()) {
+		return false
+	}
+
+	sub := t.subscription
+	if sub == nil || !chat.ok {
+		return true
+	}
+
+	if sub.FromMe != nil && *sub.FromMe != chat.fromMe {
+		return false
+	}
+
+	if len(sub.IncludeJids) > 0 && !slices.ContainsFunc(sub.IncludeJids, chat.matches) {
+		return false
+	}
+
+	return !slices.ContainsFunc(sub.ExcludeJids, chat.matches)
+}
+
+// wookChat is the chat an event belongs to; ok is false for events that are not
+// tied to a single chat, such as connection updates. messageID is set for
+// events about one message.
+type wookChat struct {
+	jid       string
+	lid       string
+	fromMe    bool
+	messageID string
+	ok        bool
+}
+
+// matches accepts a full JID, a bare number (user part) or a server suffix such
+// as "@g.us".
+func (c wookChat) matches(pattern string) bool {
+	for _, jid := range []string{c.jid, c.lid} {
+		if jid == "" {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(pattern, "@"):
+			if strings.HasSuffix(jid, pattern) {
+				return true
+			}
+		case strings.Contains(pattern, "@"):
+			if jid == pattern {
+				return true
+			}
+		default:
+			if user, _, _ := strings.Cut(jid, "@"); user == pattern {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (e *WookEvent[data]) chat() wookChat {
+	switch d := any(e.Data).(type) {
+	case *WookMessageData:
+		if d.Key != nil {
+			return wookChat{jid: d.Key.RemoteJid, lid: d.Key.RemoteLid, fromMe: d.Key.FromMe, messageID: d.Key.Id, ok: true}
+		}
+	case *WookMessageUpdateData:
+		return wookChat{jid: d.RemoteJid, lid: d.RemoteLid, fromMe: d.FromMe, messageID: d.KeyId, ok: true}
+	case *WookMessageEditData:
+		if d.Key != nil {
+			return wookChat{jid: d.Key.RemoteJid, lid: d.Key.RemoteLid, fromMe: d.Key.FromMe, messageID: d.Key.Id, ok: true}
+		}
+	case *WookMessageDeleteData:
+		return wookChat{jid: d.RemoteJid, fromMe: d.FromMe, messageID: d.Id, ok: true}
+	case *WookCallData:
+		return wookChat{jid: d.ChatId, lid: d.FromLid, ok: true}
+	case *WookPresenceData:
+		return wookChat{jid: d.Id, ok: true}
+	case *WookGroupParticipantsData:
+		return wookChat{jid: d.Id, ok: true}
+	case *WookGroupsUpdateData:
+		if len(*d) == 1 {
+			return wookChat{jid: (*d)[0].Id, ok: true}
+		}
+	case *WookGroupsUpsertData:
+		if len(*d) == 1 {
+			return wookChat{jid: (*d)[0].Id, ok: true}
+		}
+	case *WookMessagesSetData:
+		return wookChat{jid: d.RemoteJid, lid: d.RemoteLid, ok: true}
+	}
+
+	return wookChat{}
+}
+
+// withoutBase64 returns the event without inline media for targets that did
+// not ask for it. The original is left untouched since other targets share it.
+func (e *WookEvent[data]) withoutBase64() wookPayload {
+	var stripped any
+	switch d := any(e.Data).(type) {
+	case *WookMessageData:
+		if msg, ok := messageWithoutBase64(d); ok {
+			stripped = msg
+		}
+	case *WookMessagesSetData:
+		var messages []WookMessageData
+		for i := range d.Messages {
+			msg, ok := messageWithoutBase64(&d.Messages[i])
+			if !ok {
+				continue
+			}
+			if messages == nil {
+				messages = slices.Clone(d.Messages)
+			}
+			messages[i] = *msg
+		}
+		if messages != nil {
+			setCopy := *d
+			setCopy.Messages = messages
+			stripped = &setCopy
+		}
+	}
+
+	if stripped == nil {
+		return e
+	}
+
+	eventCopy := *e
+	eventCopy.Data = stripped.(*data)
+	return &eventCopy
+}
+
+// messageWithoutBase64 copies the message without inline media, ok is false
+// when it had none.
+func messageWithoutBase64(msg *WookMessageData) (*WookMessageData, bool) {
+	if msg.Message == nil || msg.Message.Base64 == "" {
+		return nil, false
+	}
+
+	raw := *msg.Message
+	raw.Base64 = ""
+	msgCopy := *msg
+	msgCopy.Message = &raw
+	return &msgCopy, true
+}
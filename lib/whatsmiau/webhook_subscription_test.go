@@ -0,0 +1,59 @@
+package whatsmiau
+
+import (
+	"testing"
+
+	"github.com/verbeux-ai/whatsmiau/models"
+)
+
+func TestWookChatMatches(t *testing.T) {
+	chat := wookChat{jid: "5511999999999@s.whatsapp.net", lid: "123456@lid", ok: true}
+
+	tests := []struct {
+		pattern string
+		want    bool
+	}{
+		{"5511999999999@s.whatsapp.net", true},
+		{"5511999999999", true},
+		{"@s.whatsapp.net", true},
+		{"@lid", true},
+		{"123456", true},
+		{"@g.us", false},
+		{"5511888888888", false},
+	}
+
+	for _, tt := range tests {
+		if got := chat.matches(tt.pattern); got != tt.want {
+			t.Errorf("matches(%q) = %v, want %v", tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestWebhookTargetAccepts(t *testing.T) {
+	fromMe := false
+	sub := &models.WebhookSubscription{
+		InstanceWebhook: models.InstanceWebhook{Events: []string{"MESSAGES_UPSERT", "CONNECTION_UPDATE"}},
+		ExcludeJids:     []string{"@g.us"},
+		FromMe:          &fromMe,
+	}
+	target := webhookTarget{subscription: sub, webhook: &sub.InstanceWebhook}
+
+	tests := []struct {
+		name  string
+		event Wook
+		chat  wookChat
+		want  bool
+	}{
+		{"received direct message", WookMessagesUpsert, wookChat{jid: "5511999999999@s.whatsapp.net", ok: true}, true},
+		{"sent message", WookMessagesUpsert, wookChat{jid: "5511999999999@s.whatsapp.net", fromMe: true, ok: true}, false},
+		{"excluded group", WookMessagesUpsert, wookChat{jid: "120363000000000000@g.us", ok: true}, false},
+		{"event not subscribed", WookMessagesUpdate, wookChat{jid: "5511999999999@s.whatsapp.net", ok: true}, false},
+		{"event without chat", WookConnectionUpdate, wookChat{}, true},
+	}
+
+	for _, tt := range tests {
+		if got := target.accepts(tt.event, tt.chat); got != tt.want {
+			t.Errorf("%s: accepts = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,339 @@
+package whatsmiau
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"github.com/verbeux-ai/whatsmiau/models"
+)
+
+// maxTemplateOutput bounds the body a template may produce, so a template
+// that ranges over a huge number cannot exhaust memory.
+const maxTemplateOutput = 1 << 20
+
+// maxTemplateIterations bounds the range iterations of one execution, which
+// the output limit does not catch when they print nothing, as in
+// {{range 9223372036854775807}}{{end}}.
+const maxTemplateIterations = 100000
+
+// maxTemplateFormatWidth bounds the width and precision of printf verbs: fmt
+// pads in memory, before anything reaches the output limit.
+const maxTemplateFormatWidth = 1024
+
+// templateIterationFunc is called at the start of every range iteration to
+// count it. It is added to the parsed templates, not written by users.
+const templateIterationFunc = "rangeIteration"
+
+var (
+	ErrTemplateTooLarge    = errors.New("webhook template output is too large")
+	ErrTemplateNotJSON     = errors.New("webhook template output is not valid JSON")
+	ErrTemplateTooLong     = errors.New("webhook template runs too many iterations")
+	errTemplateOutputFull  = errors.New("template output limit reached")
+	errTemplateLoopTooLong = errors.New("template iteration limit reached")
+)
+
+// templateFuncs is everything a webhook template may call besides the
+// text/template builtins, of which the printing ones are replaced by bounded
+// versions. None of them reach outside the event, and the value comes last so
+// they can be piped: {{.data.pushName | default "unknown"}}.
+var templateFuncs = template.FuncMap{
+	"print": func(args ...any) (string, error) {
+		return limitTemplateString(fmt.Sprint(args...))
+	},
+	"println": func(args ...any) (string, error) {
+		return limitTemplateString(fmt.Sprintln(args...))
+	},
+	"printf": func(format string, args ...any) (string, error) {
+		if err := checkTemplateFormat(format); err != nil {
+			return "", err
+		}
+		return limitTemplateString(fmt.Sprintf(format, args...))
+	},
+	"json": func(v any) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"trim":  strings.TrimSpace,
+	"replace": func(old, new, s string) (string, error) {
+		// an empty old matches around every rune
+		if size := len(s) + strings.Count(s, old)*(len(new)-len(old)); size > maxTemplateOutput {
+			return "", errTemplateOutputFull
+		}
+		return strings.ReplaceAll(s, old, new), nil
+	},
+	"contains": func(substr, s string) bool {
+		return strings.Contains(s, substr)
+	},
+	"hasPrefix": func(prefix, s string) bool {
+		return strings.HasPrefix(s, prefix)
+	},
+	"join": func(sep string, v any) string {
+		elems, _ := v.([]any)
+		parts := make([]string, len(elems))
+		for i, elem := range elems {
+			parts[i] = fmt.Sprint(elem)
+		}
+		return strings.Join(parts, sep)
+	},
+	"truncate": func(n int, s string) string {
+		if r := []rune(s); len(r) > n {
+			return string(r[:n])
+		}
+		return s
+	},
+	"default": func(fallback, v any) any {
+		if v == nil || v == "" {
+			return fallback
+		}
+		return v
+	},
+	"phone": func(jid string) string { // user part of a JID, without device
+		user, _, _ := strings.Cut(jid, "@")
+		user, _, _ = strings.Cut(user, ":")
+		return user
+	},
+	"formatTime": func(layout string, v any) string { // unix seconds
+		var sec int64
+		switch n := v.(type) {
+		case json.Number:
+			sec, _ = n.Int64()
+		case float64:
+			sec = int64(n)
+		case int:
+			sec = int64(n)
+		}
+		return time.Unix(sec, 0).UTC().Format(layout)
+	},
+}
+
+func limitTemplateString(s string) (string, error) {
+	if len(s) > maxTemplateOutput {
+		return "", errTemplateOutputFull
+	}
+	return s, nil
+}
+
+// checkTemplateFormat refuses printf formats with a width or precision above
+// maxTemplateFormatWidth, or taken from the arguments with *.
+func checkTemplateFormat(format string) error {
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			continue
+		}
+
+		i++
+		for i < len(format) && strings.IndexByte("+-# 0", format[i]) >= 0 {
+			i++
+		}
+		for part := 0; part < 2; part++ { // width, then precision
+			if part == 1 {
+				if i >= len(format) || format[i] != '.' {
+					break
+				}
+				i++
+			}
+			if i < len(format) && format[i] == '[' {
+				if end := strings.IndexByte(format[i:], ']'); end >= 0 {
+					i += end + 1
+				}
+			}
+			if i < len(format) && format[i] == '*' {
+				return fmt.Errorf("%w: printf width from arguments", errTemplateOutputFull)
+			}
+
+			n := 0
+			for ; i < len(format) && format[i] >= '0' && format[i] <= '9'; i++ {
+				if n = n*10 + int(format[i]-'0'); n > maxTemplateFormatWidth {
+					return fmt.Errorf("%w: printf width above %d", errTemplateOutputFull, maxTemplateFormatWidth)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// ParseWebhookTemplate compiles a body template. Templates see the event as
+// sent without a template, e.g. {{.event}}, {{.instance}} and {{.data.key.id}},
+// and must render valid JSON.
+func ParseWebhookTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("webhook").
+		Funcs(templateFuncs).
+		Funcs(template.FuncMap{templateIterationFunc: func() string { return "" }}).
+		Option("missingkey=zero").
+		Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil {
+			countRangeIterations(t.Tree, t.Tree.Root)
+		}
+	}
+
+	return tmpl, nil
+}
+
+// countRangeIterations prepends a call to templateIterationFunc to the body of
+// every range in the tree.
+func countRangeIterations(tree *parse.Tree, node parse.Node) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			countRangeIterations(tree, child)
+		}
+	case *parse.IfNode:
+		countRangeIterations(tree, n.List)
+		countRangeIterations(tree, n.ElseList)
+	case *parse.WithNode:
+		countRangeIterations(tree, n.List)
+		countRangeIterations(tree, n.ElseList)
+	case *parse.RangeNode:
+		countRangeIterations(tree, n.List)
+		countRangeIterations(tree, n.ElseList)
+
+		call := &parse.ActionNode{
+			NodeType: parse.NodeAction,
+			Pos:      n.Pos,
+			Line:     n.Line,
+			Pipe: &parse.PipeNode{
+				NodeType: parse.NodePipe,
+				Pos:      n.Pos,
+				Line:     n.Line,
+				Cmds: []*parse.CommandNode{{
+					NodeType: parse.NodeCommand,
+					Pos:      n.Pos,
+					Args:     []parse.Node{parse.NewIdentifier(templateIterationFunc).SetTree(tree).SetPos(n.Pos)},
+				}},
+			},
+		}
+		n.List.Nodes = append([]parse.Node{call}, n.List.Nodes...)
+	}
+}
+
+func templateEnabled(webhook *models.InstanceWebhook) bool {
+	return webhook != nil && webhook.Template != nil && *webhook.Template != ""
+}
+
+// compiledTemplate is the template of a webhook, kept with its text so a
+// change is noticed.
+type compiledTemplate struct {
+	text string
+	tmpl *template.Template
+}
+
+// renderWebhookTemplate maps the JSON encoded event through the template of
+// the webhook. The compiled template is kept per webhook (key, see
+// webhookTargetKey), so the cache holds one per target and is replaced when
+// the template changes.
+func (s *Whatsmiau) renderWebhookTemplate(key, text string, data []byte) ([]byte, error) {
+	compiled, ok := s.webhookTemplates.Load(key)
+	if !ok || compiled.text != text {
+		tmpl, err := ParseWebhookTemplate(text)
+		if err != nil {
+			return nil, err
+		}
+		compiled = compiledTemplate{text: text, tmpl: tmpl}
+		s.webhookTemplates.Store(key, compiled)
+	}
+
+	return executeWebhookTemplate(compiled.tmpl, data)
+}
+
+// forgetWebhookTemplates drops the compiled templates of an instance.
+func (s *Whatsmiau) forgetWebhookTemplates(instanceID string) {
+	s.webhookTemplates.Range(func(key string, _ compiledTemplate) bool {
+		if strings.HasPrefix(key, instanceID+"|") {
+			s.webhookTemplates.Delete(key)
+		}
+		return true
+	})
+}
+
+func executeWebhookTemplate(tmpl *template.Template, data []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var event map[string]any
+	if err := decoder.Decode(&event); err != nil {
+		return nil, err
+	}
+
+	// the iteration count is per execution, so it runs on a copy
+	iterations := 0
+	tmpl, err := tmpl.Clone()
+	if err != nil {
+		return nil, err
+	}
+	tmpl.Funcs(template.FuncMap{templateIterationFunc: func() (string, error) {
+		iterations++
+		if iterations > maxTemplateIterations {
+			return "", errTemplateLoopTooLong
+		}
+		return "", nil
+	}})
+
+	out := &limitedBuffer{max: maxTemplateOutput}
+	if err := tmpl.Execute(out, event); err != nil {
+		if errors.Is(err, errTemplateOutputFull) {
+			return nil, ErrTemplateTooLarge
+		}
+		if errors.Is(err, errTemplateLoopTooLong) {
+			return nil, ErrTemplateTooLong
+		}
+		return nil, err
+	}
+
+	body := bytes.TrimSpace(out.Bytes())
+	if !json.Valid(body) {
+		return nil, ErrTemplateNotJSON
+	}
+
+	return body, nil
+}
+
+// PreviewWebhookTemplate renders the template against a synthetic event of the
+// instance, the one the test-fire endpoint sends, so it can be checked before
+// it is saved.
+func PreviewWebhookTemplate(instance *models.Instance, text string, event Wook) (json.RawMessage, error) {
+	tmpl, err := ParseWebhookTemplate(text)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := testEvent(instance, event)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	return executeWebhookTemplate(tmpl, data)
+}
+
+type limitedBuffer struct {
+	bytes.Buffer
+	max int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > b.max {
+		return 0, errTemplateOutputFull
+	}
+	return b.Buffer.Write(p)
+}
@@ -0,0 +1,77 @@
+package whatsmiau
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/verbeux-ai/whatsmiau/models"
+)
+
+func TestPreviewWebhookTemplateMapsEvent(t *testing.T) {
+	text := `{"text": {{printf "%s: %s" (.data.pushName | default "unknown") .data.message.conversation | json}}, "phone": {{.data.key.remoteJid | phone | json}}, "event": {{.event | upper | json}}}`
+
+	preview, err := PreviewWebhookTemplate(&models.Instance{ID: "acme"}, text, WookMessagesUpsert)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var body map[string]string
+	if err := json.Unmarshal(preview, &body); err != nil {
+		t.Fatal(err)
+	}
+	if body["text"] != testChatPushName+": This is a test message from Whatsmiau" {
+		t.Errorf("text = %q", body["text"])
+	}
+	if body["phone"] != "5511999999999" {
+		t.Errorf("phone = %q", body["phone"])
+	}
+	if body["event"] != "MESSAGES.UPSERT" {
+		t.Errorf("event = %q", body["event"])
+	}
+}
+
+func TestPreviewWebhookTemplateBoundedPrintf(t *testing.T) {
+	body, err := PreviewWebhookTemplate(&models.Instance{ID: "acme"}, `{"n": "{{printf "%05d|%-4s|%.2f" 42 "ab" 3.14159}}", "s": "{{print "a" 1}}"}`, WookMessagesUpsert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != `{"n": "00042|ab  |3.14", "s": "a1"}` {
+		t.Errorf("body = %s", body)
+	}
+}
+
+func TestPreviewWebhookTemplateRejectsBadTemplates(t *testing.T) {
+	instance := &models.Instance{ID: "acme"}
+
+	if _, err := PreviewWebhookTemplate(instance, `{"id": {{.data.key.id}`, WookMessagesUpsert); err == nil {
+		t.Error("unterminated action was accepted")
+	}
+
+	if _, err := PreviewWebhookTemplate(instance, `{"id": {{.data.key.id}}}`, WookMessagesUpsert); !errors.Is(err, ErrTemplateNotJSON) {
+		t.Errorf("unquoted string: err = %v, want ErrTemplateNotJSON", err)
+	}
+
+	if _, err := PreviewWebhookTemplate(instance, `[{{range 10000000}}"0000000000000000000",{{end}}0]`, WookMessagesUpsert); !errors.Is(err, ErrTemplateTooLarge) {
+		t.Errorf("huge output: err = %v, want ErrTemplateTooLarge", err)
+	}
+
+	if _, err := PreviewWebhookTemplate(instance, `{{range 9223372036854775807}}{{end}}{}`, WookMessagesUpsert); !errors.Is(err, ErrTemplateTooLong) {
+		t.Errorf("endless range: err = %v, want ErrTemplateTooLong", err)
+	}
+
+	for _, text := range []string{
+		`{"n": "{{printf "%0999999999d" 1}}"}`,
+		`{"n": "{{printf "%.999999999f" 1.0}}"}`,
+		`{"n": "{{printf "%*d" 999999999 1}}"}`,
+		`{"n": "{{replace "" "0000000000000000" (printf "%01000d" 1) | replace "" "0000000000000000" | replace "" "0000000000000000"}}"}`,
+	} {
+		if _, err := PreviewWebhookTemplate(instance, text, WookMessagesUpsert); !errors.Is(err, ErrTemplateTooLarge) {
+			t.Errorf("%s: err = %v, want ErrTemplateTooLarge", text, err)
+		}
+	}
+
+	if _, err := PreviewWebhookTemplate(instance, `{{define "loop"}}{{range 1000}}{{end}}{{end}}{{range 1000}}{{template "loop"}}{{end}}{}`, WookMessagesUpsert); !errors.Is(err, ErrTemplateTooLong) {
+		t.Errorf("nested range: err = %v, want ErrTemplateTooLong", err)
+	}
+}
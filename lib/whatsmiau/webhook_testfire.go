@@ -0,0 +1,202 @@
+package whatsmiau
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/verbeux-ai/whatsmiau/models"
+)
+
+var (
+	ErrUnsupportedTestEvent   = errors.New("event cannot be test fired")
+	ErrTestSubscriptionAbsent = errors.New("webhook subscription not found")
+	ErrTestWebhookURLEmpty    = errors.New("webhook has no url for the event")
+)
+
+// The synthetic events come from this made up contact.
+const (
+	testChatJid      = "5511999999999@s.whatsapp.net"
+	testChatLid      = "123456789012345@lid"
+	testChatPushName = "Whatsmiau Test"
+)
+
+// TestWebhook delivers a synthetic event of the given type to the main webhook
+// of the instance, to one of its subscriptions or, with subscription "global",
+// to the global webhook. It goes through doEmit like real deliveries, with
+// headers, auth and signing, but once and without retries. Batching webhooks
+// get the event as a batch of one.
+func (s *Whatsmiau) TestWebhook(instance *models.Instance, subscription string, event Wook) (*models.WebhookTestResult, error) {
+	webhook, err := s.testWebhookTarget(instance, subscription)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := testEvent(instance, event)
+	if err != nil {
+		return nil, err
+	}
+
+	url := webhookURL(webhook, event)
+	if url == "" {
+		return nil, ErrTestWebhookURLEmpty
+	}
+
+	payload := body
+	if !base64Enabled(webhook) {
+		payload = body.withoutBase64()
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	if templateEnabled(webhook) {
+		if data, err = s.renderWebhookTemplate(webhookTargetKey(instance.ID, subscription), *webhook.Template, data); err != nil {
+			return nil, err
+		}
+	}
+	if batchEnabled(webhook) {
+		data = append(append([]byte("["), data...), ']')
+	}
+
+	start := time.Now()
+	result := s.doEmit(data, url, webhookTargetKey(instance.ID, subscription), webhook)
+
+	return &models.WebhookTestResult{
+		Event:      event.ConfigName(),
+		Url:        url,
+		Success:    result.Success,
+		StatusCode: result.StatusCode,
+		Response:   result.Response,
+		Error:      result.Error,
+		LatencyMs:  time.Since(start).Milliseconds(),
+		Payload:    data,
+	}, nil
+}
+
+func (s *Whatsmiau) testWebhookTarget(instance *models.Instance, subscription string) (*models.InstanceWebhook, error) {
+	switch subscription {
+	case "":
+		return &instance.Webhook, nil
+	case globalWebhookID:
+		if s.globalWebhook == nil {
+			return nil, ErrTestSubscriptionAbsent
+		}
+		return s.globalWebhook, nil
+	}
+
+	for i := range instance.Subscriptions {
+		if instance.Subscriptions[i].ID == subscription {
+			return &instance.Subscriptions[i].InstanceWebhook, nil
+		}
+	}
+
+	return nil, ErrTestSubscriptionAbsent
+}
+
+// testEvent builds a realistic event of the instance with a made up chat.
+func testEvent(instance *models.Instance, event Wook) (wookPayload, error) {
+	now := time.Now()
+	messageID := testMessageID()
+
+	switch event {
+	case WookMessagesUpsert:
+		return &WookEvent[WookMessageData]{
+			Instance: instance.ID,
+			Data: &WookMessageData{
+				Key: &WookKey{
+					RemoteJid: testChatJid,
+					RemoteLid: testChatLid,
+					Id:        messageID,
+				},
+				PushName:         testChatPushName,
+				Status:           "DELIVERY_ACK",
+				Message:          &WookMessageRaw{Conversation: "This is a test message from Whatsmiau"},
+				ContextInfo:      &WookMessageContextInfo{},
+				MessageType:      "conversation",
+				MessageTimestamp: int(now.Unix()),
+				InstanceId:       instance.ID,
+				Source:           "whatsapp",
+			},
+			DateTime: now,
+			Event:    event,
+		}, nil
+	case WookMessagesUpdate:
+		return &WookEvent[WookMessageUpdateData]{
+			Instance: instance.ID,
+			Data: &WookMessageUpdateData{
+				MessageId:  messageID,
+				KeyId:      messageID,
+				RemoteJid:  testChatJid,
+				RemoteLid:  testChatLid,
+				FromMe:     true,
+				Status:     MessageStatusRead,
+				InstanceId: instance.ID,
+			},
+			DateTime: now,
+			Event:    event,
+		}, nil
+	case WookMessagesDelete:
+		return &WookEvent[WookMessageDeleteData]{
+			Instance: instance.ID,
+			Data: &WookMessageDeleteData{
+				Id:         messageID,
+				RemoteJid:  testChatJid,
+				Status:     "DELETED",
+				InstanceId: instance.ID,
+			},
+			DateTime: now,
+			Event:    event,
+		}, nil
+	case WookContactsUpsert:
+		return &WookEvent[WookContactUpsertData]{
+			Instance: instance.ID,
+			Data: &WookContactUpsertData{{
+				RemoteJid:  testChatJid,
+				RemoteLid:  testChatLid,
+				PushName:   testChatPushName,
+				InstanceId: instance.ID,
+			}},
+			DateTime: now,
+			Event:    event,
+		}, nil
+	case WookConnectionUpdate:
+		return &WookEvent[WookConnectionUpdateData]{
+			Instance: instance.ID,
+			Data: &WookConnectionUpdateData{
+				Instance: instance.ID,
+				Wuid:     instance.RemoteJID,
+				State:    "open",
+			},
+			DateTime: now,
+			Event:    event,
+		}, nil
+	case WookCall:
+		return &WookEvent[WookCallData]{
+			Instance: instance.ID,
+			Data: &WookCallData{
+				Id:         testMessageID(),
+				From:       testChatJid,
+				FromLid:    testChatLid,
+				ChatId:     testChatJid,
+				Status:     CallStatusOffer,
+				Date:       now,
+				InstanceId: instance.ID,
+			},
+			DateTime: now,
+			Event:    event,
+		}, nil
+	}
+
+	return nil, ErrUnsupportedTestEvent
+}
+
+// testMessageID looks like the ids of messages sent from WhatsApp Web.
+func testMessageID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return "3EB0" + strings.ToUpper(hex.EncodeToString(b))
+}
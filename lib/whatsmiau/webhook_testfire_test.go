@@ -0,0 +1,81 @@
+package whatsmiau
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/verbeux-ai/whatsmiau/models"
+)
+
+func TestTestWebhook(t *testing.T) {
+	var received map[string]any
+	var signature string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signature = r.Header.Get("X-Webhook-Signature")
+		body, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(body, &received)
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	defer server.Close()
+
+	byEvents := true
+	instance := &models.Instance{
+		ID:      "inst",
+		Webhook: models.InstanceWebhook{Url: server.URL, ByEvents: &byEvents, Secret: "s3cret"},
+	}
+
+	result, err := newAuthTestMiau().TestWebhook(instance, "", WookMessagesUpsert)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !result.Success || result.StatusCode != http.StatusAccepted || result.Response != `{"ok":true}` {
+		t.Fatalf("result = %+v", result)
+	}
+	if result.Url != server.URL+"/messages-upsert" {
+		t.Errorf("url = %q, want the by-events url", result.Url)
+	}
+	if signature == "" {
+		t.Error("test delivery was not signed")
+	}
+	if received["event"] != string(WookMessagesUpsert) || received["instance"] != "inst" {
+		t.Errorf("received payload = %v", received)
+	}
+
+	if _, err := newAuthTestMiau().TestWebhook(instance, "", WookInstanceCreate); !errors.Is(err, ErrUnsupportedTestEvent) {
+		t.Errorf("application event err = %v, want ErrUnsupportedTestEvent", err)
+	}
+	if _, err := newAuthTestMiau().TestWebhook(instance, "missing", WookMessagesUpsert); !errors.Is(err, ErrTestSubscriptionAbsent) {
+		t.Errorf("unknown subscription err = %v, want ErrTestSubscriptionAbsent", err)
+	}
+}
+
+func TestTestWebhookBatch(t *testing.T) {
+	var received []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(body, &received)
+	}))
+	defer server.Close()
+
+	instance := &models.Instance{
+		ID:      "inst",
+		Webhook: models.InstanceWebhook{Url: server.URL, Batch: &models.WebhookBatch{Size: 10}},
+	}
+
+	result, err := newAuthTestMiau().TestWebhook(instance, "", WookMessagesUpsert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Success {
+		t.Fatalf("result = %+v", result)
+	}
+	if len(received) != 1 || received[0]["event"] != string(WookMessagesUpsert) {
+		t.Errorf("expected a batch of one event, got %v", received)
+	}
+}
@@ -0,0 +1,32 @@
+package whatsmiau
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/verbeux-ai/whatsmiau/models"
+)
+
+// webhookURL resolves where an event is delivered. An explicit EventUrls entry
+// wins; otherwise, with ByEvents on, the event path is appended to Url the way
+// Evolution API does (…/messages-upsert).
+func webhookURL(webhook *models.InstanceWebhook, event Wook) string {
+	if eventURL := webhook.EventUrls[event.ConfigName()]; eventURL != "" {
+		return eventURL
+	}
+
+	if webhook.Url == "" {
+		return ""
+	}
+
+	if webhook.ByEvents != nil && *webhook.ByEvents {
+		// the event goes on the path, before any query string of Url
+		u, err := url.Parse(webhook.Url)
+		if err != nil {
+			return strings.TrimRight(webhook.Url, "/") + "/" + event.Path()
+		}
+		return u.JoinPath(event.Path()).String()
+	}
+
+	return webhook.Url
+}
@@ -0,0 +1,60 @@
+package whatsmiau
+
+import (
+	"testing"
+
+	"github.com/verbeux-ai/whatsmiau/models"
+)
+
+func TestWebhookURL(t *testing.T) {
+	byEvents := true
+	cases := []struct {
+		name    string
+		webhook models.InstanceWebhook
+		event   Wook
+		want    string
+	}{
+		{
+			name:    "plain url",
+			webhook: models.InstanceWebhook{Url: "https://hook.example/wh"},
+			event:   WookMessagesUpsert,
+			want:    "https://hook.example/wh",
+		},
+		{
+			name:    "by events",
+			webhook: models.InstanceWebhook{Url: "https://hook.example/wh/", ByEvents: &byEvents},
+			event:   WookConnectionUpdate,
+			want:    "https://hook.example/wh/connection-update",
+		},
+		{
+			name:    "by events with query",
+			webhook: models.InstanceWebhook{Url: "https://hook.example/wh?token=abc&env=prod", ByEvents: &byEvents},
+			event:   WookMessagesUpsert,
+			want:    "https://hook.example/wh/messages-upsert?token=abc&env=prod",
+		},
+		{
+			name: "explicit event url",
+			webhook: models.InstanceWebhook{
+				Url:       "https://hook.example/wh",
+				ByEvents:  &byEvents,
+				EventUrls: map[string]string{"MESSAGES_UPSERT": "https://bot.example/in"},
+			},
+			event: WookMessagesUpsert,
+			want:  "https://bot.example/in",
+		},
+		{
+			name:    "no url",
+			webhook: models.InstanceWebhook{ByEvents: &byEvents},
+			event:   WookMessagesUpsert,
+			want:    "",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := webhookURL(&c.webhook, c.event); got != c.want {
+				t.Errorf("webhookURL() = %q, want %q", got, c.want)
+			}
+		})
+	}
+}
@@ -5,14 +5,19 @@ import (
 	"net/http"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/go-redis/redis/v8"
 	"github.com/puzpuzpuz/xsync/v4"
 	"github.com/verbeux-ai/whatsmiau/env"
 	"github.com/verbeux-ai/whatsmiau/interfaces"
 	"github.com/verbeux-ai/whatsmiau/lib/storage/gcs"
 	"github.com/verbeux-ai/whatsmiau/models"
+	"github.com/verbeux-ai/whatsmiau/repositories/deadletters"
+	"github.com/verbeux-ai/whatsmiau/repositories/deliveries"
 	"github.com/verbeux-ai/whatsmiau/repositories/instances"
+	"github.com/verbeux-ai/whatsmiau/repositories/polls"
 	"github.com/verbeux-ai/whatsmiau/services"
 	"go.mau.fi/whatsmeow"
 	"go.mau.fi/whatsmeow/store"
@@ -21,6 +26,7 @@ import (
 	waLog "go.mau.fi/whatsmeow/util/log"
 	"go.uber.org/zap"
 	"golang.org/x/net/context"
+	"golang.org/x/time/rate"
 )
 
 type Whatsmiau struct {
@@ -28,16 +34,38 @@ type Whatsmiau struct {
 	container          *sqlstore.Container
 	logger             waLog.Logger
 	repo               interfaces.InstanceRepository
+	deadLetters        interfaces.DeadLetterRepository
+	deliveries         interfaces.WebhookDeliveryRepository
+	polls              interfaces.PollRepository
 	qrCache            *xsync.Map[string, string]
 	pairingCache       *xsync.Map[string, string]
 	observerRunning    *xsync.Map[string, *whatsmeow.Client]
 	instanceCache      *xsync.Map[string, models.Instance]
+	instanceCacheMu    sync.Mutex
+	instanceEpoch      atomic.Uint64 // bumped by every invalidation of instanceCache
 	lockConnection     *xsync.Map[string, *sync.Mutex]
 	connectPhoneNumber *xsync.Map[string, string]
-	emitter            chan emitter
+	redis              *redis.Client
 	httpClient         *http.Client
+	webhookTokens      *xsync.Map[string, webhookToken]
+	webhookTemplates   *xsync.Map[string, compiledTemplate]
+	globalWebhook      *models.InstanceWebhook
+	stream             *eventStream
+	globalSink         *models.InstanceSink
+	sinkMu             sync.Mutex
+	sinks              map[string]*sharedSink
+	sinkOwners         map[string]string // instance (or global) to the key of its broker connection in sinks
+	breakers           *xsync.Map[string, *circuitBreaker]
+	limiters           *xsync.Map[string, *rate.Limiter]
+	handlerQueue       *keyedQueue
+	historyQueue       *keyedQueue
+	historyWorkers     chan struct{}
+	emitterInflight    *xsync.Map[string, struct{}]
+	emitterBatcher     *emitBatcher
+	keptOnline         *xsync.Map[string, struct{}]
 	fileStorage        interfaces.Storage
 	handlerSemaphore   chan struct{}
+	handlerQueued      chan struct{}
 }
 
 var instance *Whatsmiau
@@ -62,7 +90,8 @@ func LoadMiau(ctx context.Context, container *sqlstore.Container) {
 		level = "DEBUG"
 	}
 
-	repo := instances.NewRedis(services.Redis())
+	redisClient := services.Redis()
+	repo := instances.NewRedis(redisClient)
 	instanceList, err := repo.List(ctx, "")
 	if err != nil {
 		zap.L().Fatal("failed to list instances", zap.Error(err))
@@ -128,21 +157,48 @@ func LoadMiau(ctx context.Context, container *sqlstore.Container) {
 		container:          container,
 		logger:             clientLog,
 		repo:               repo,
+		deadLetters:        deadletters.NewRedis(redisClient, env.Env.WebhookDeadLetterMaxLen),
+		deliveries:         deliveries.NewRedis(redisClient, env.Env.WebhookDeliveryLogSize),
+		polls:              polls.NewRedis(redisClient, env.Env.PollRetention),
 		qrCache:            xsync.NewMap[string, string](),
 		pairingCache:       xsync.NewMap[string, string](),
 		instanceCache:      xsync.NewMap[string, models.Instance](),
 		observerRunning:    xsync.NewMap[string, *whatsmeow.Client](),
 		lockConnection:     xsync.NewMap[string, *sync.Mutex](),
 		connectPhoneNumber: xsync.NewMap[string, string](),
-		emitter:            make(chan emitter, env.Env.EmitterBufferSize),
+		redis:              redisClient,
 		httpClient: &http.Client{
 			Timeout: time.Second * 30, // TODO: load from env
 		},
+		webhookTokens:    xsync.NewMap[string, webhookToken](),
+		webhookTemplates: xsync.NewMap[string, compiledTemplate](),
+		globalWebhook:    newGlobalWebhook(env.Env),
+		stream:           newEventStream(env.Env.EventStreamBufferSize),
+		globalSink:       newGlobalSink(env.Env),
+		sinks:            make(map[string]*sharedSink),
+		sinkOwners:       make(map[string]string),
+		breakers:         xsync.NewMap[string, *circuitBreaker](),
+		limiters:         xsync.NewMap[string, *rate.Limiter](),
+		handlerQueue:     newKeyedQueue(),
+		historyQueue:     newKeyedQueue(),
+		historyWorkers:   make(chan struct{}, max(env.Env.HistorySyncWorkers, 1)),
+		emitterInflight:  xsync.NewMap[string, struct{}](),
+		emitterBatcher:   newEmitBatcher(),
+		keptOnline:       xsync.NewMap[string, struct{}](),
 		fileStorage:      storage,
 		handlerSemaphore: make(chan struct{}, env.Env.HandlerSemaphoreSize),
+		handlerQueued:    make(chan struct{}, env.Env.HandlerSemaphoreSize),
+	}
+
+	if instance.globalSink != nil {
+		if _, err := instance.targetSink(brokerTarget(instance.globalSink, true), "", wookChat{}, ""); err != nil {
+			zap.L().Error("failed to connect global event sink", zap.Error(err))
+		}
 	}
 
 	go instance.startEmitter()
+	go instance.keepOnline()
+	go instance.watchSettings()
 
 	clients.Range(func(id string, client *whatsmeow.Client) bool {
 		// Store.ID can become nil between Connect() above and this Range when the
@@ -266,6 +322,7 @@ func (s *Whatsmiau) generateClient(ctx context.Context, id string) (*whatsmeow.C
 	if !ok {
 		device := s.container.NewDevice()
 		client = whatsmeow.NewClient(device, s.logger)
+		s.configHistorySync(client, id)
 		s.clients.Store(id, client)
 	}
 
@@ -298,6 +355,7 @@ func (s *Whatsmiau) generateClient(ctx context.Context, id string) (*whatsmeow.C
 
 		device := s.container.NewDevice()
 		client = whatsmeow.NewClient(device, s.logger)
+		s.configHistorySync(client, id)
 		s.clients.Store(id, client) // replaces old client
 	}
 
@@ -509,7 +567,12 @@ func (s *Whatsmiau) Logout(ctx context.Context, id string) error {
 	}
 
 	s.clients.Delete(id)
-	return s.deleteDeviceIfExists(ctx, client)
+	if err := s.deleteDeviceIfExists(ctx, client); err != nil {
+		return err
+	}
+
+	s.emitLoggedOut(id, "api")
+	return nil
 }
 
 func (s *Whatsmiau) Disconnect(id string) error {
@@ -554,7 +617,7 @@ func (s *Whatsmiau) Restart(ctx context.Context, id string) error {
 	s.qrCache.Delete(id)
 	s.pairingCache.Delete(id)
 	s.observerRunning.Delete(id)
-	s.instanceCache.Delete(id)
+	s.invalidateInstance(id)
 
 	// Find device: old client's Store, or scan SQL store
 	var device *store.Device
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// DeadLetter is a webhook delivery that kept failing after every retry.
+type DeadLetter struct {
+	ID           string          `json:"id"`
+	InstanceID   string          `json:"instanceId"`
+	Subscription string          `json:"subscription,omitempty"` // empty for the main webhook
+	Event        string          `json:"event,omitempty"`
+	Url          string          `json:"url"`
+	Payload      json.RawMessage `json:"payload"`
+	StatusCode   int             `json:"statusCode,omitempty"`
+	Response     string          `json:"response,omitempty"`
+	Error        string          `json:"error,omitempty"`
+	Attempts     int             `json:"attempts"`
+	Batch        int             `json:"batch,omitempty"` // events in the payload array of a batched delivery
+	FailedAt     time.Time       `json:"failedAt"`
+}
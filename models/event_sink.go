@@ -0,0 +1,62 @@
+package models
+
+import "net/url"
+
+type EventSinkType string
+
+const (
+	EventSinkNats EventSinkType = "nats"
+	EventSinkAmqp EventSinkType = "amqp"
+)
+
+// InstanceSink publishes the events of an instance to a message broker, next
+// to its webhooks. Events and Base64 work as they do on InstanceWebhook.
+type InstanceSink struct {
+	Enabled *bool         `json:"enabled,omitempty"`
+	Type    EventSinkType `json:"type,omitempty" validate:"omitempty,oneof=nats amqp"`
+	Url     string        `json:"url,omitempty" validate:"required_with=Type"`
+	// Prefix is the first token of the NATS subject, whatsmiau by default.
+	Prefix string `json:"prefix,omitempty"`
+	// Exchange is the AMQP topic exchange, whatsmiau by default.
+	Exchange string   `json:"exchange,omitempty"`
+	Base64   *bool    `json:"base64,omitempty"`
+	Events   []string `json:"events,omitempty"`
+}
+
+// Redacted is the sink as the API returns it, with the credentials of its URL
+// masked.
+func (s *InstanceSink) Redacted() *InstanceSink {
+	if s == nil {
+		return nil
+	}
+
+	redacted := *s
+	redacted.Url = redactSinkURL(s.Url)
+	return &redacted
+}
+
+// KeepMasked puts back the stored URL when the update sends the masked one,
+// so a sink read from the API can be sent back as is.
+func (s *InstanceSink) KeepMasked(old *InstanceSink) {
+	if s == nil || old == nil {
+		return
+	}
+
+	if s.Url != "" && s.Url != old.Url && s.Url == redactSinkURL(old.Url) {
+		s.Url = old.Url
+	}
+}
+
+// redactSinkURL masks the password of a broker URL like url.URL.Redacted, or
+// its user alone, which NATS uses as a token.
+func redactSinkURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.User == nil {
+		return raw
+	}
+
+	if _, ok := u.User.Password(); !ok {
+		u.User = url.User("xxxxx")
+	}
+	return u.Redacted()
+}
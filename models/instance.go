@@ -1,5 +1,14 @@
 package models
 
+import (
+	"errors"
+	"time"
+)
+
+// ErrBatchedTemplate rejects webhooks that both batch and template their
+// deliveries: templates map single events.
+var ErrBatchedTemplate = errors.New("webhook batch cannot be combined with a template")
+
 type Instance struct {
 	ID                string          `json:"id,omitempty"`
 	RejectCall        bool            `json:"rejectCall,omitempty"`
@@ -12,9 +21,61 @@ type Instance struct {
 	SyncRecentHistory bool            `json:"syncRecentHistory,omitempty"`
 	RemoteJID         string          `json:"remoteJID,omitempty"`
 	Webhook           InstanceWebhook `json:"webhook,omitempty"`
+	// Subscriptions are additional webhook targets, each with its own filters.
+	Subscriptions []WebhookSubscription `json:"subscriptions,omitempty"`
+	// Sink additionally publishes the events to a message broker.
+	Sink *InstanceSink `json:"sink,omitempty"`
 	InstanceProxy
 }
 
+// Redacted is the instance as the API returns it, without the secrets of its
+// webhooks.
+func (i Instance) Redacted() *Instance {
+	i.Webhook = i.Webhook.Redacted()
+	if i.Subscriptions != nil {
+		subscriptions := make([]WebhookSubscription, len(i.Subscriptions))
+		for j, subscription := range i.Subscriptions {
+			subscriptions[j] = subscription.Redacted()
+		}
+		i.Subscriptions = subscriptions
+	}
+	i.Sink = i.Sink.Redacted()
+	return &i
+}
+
+// InstanceSettings is a partial update of the behavior settings of an
+// instance; nil fields are left as they are.
+type InstanceSettings struct {
+	RejectCall        *bool   `json:"rejectCall,omitempty"`
+	MsgCall           *string `json:"msgCall,omitempty"`
+	GroupsIgnore      *bool   `json:"groupsIgnore,omitempty"`
+	AlwaysOnline      *bool   `json:"alwaysOnline,omitempty"`
+	ReadMessages      *bool   `json:"readMessages,omitempty"`
+	ReadStatus        *bool   `json:"readStatus,omitempty"`
+	SyncFullHistory   *bool   `json:"syncFullHistory,omitempty"`
+	SyncRecentHistory *bool   `json:"syncRecentHistory,omitempty"`
+}
+
+// ApplyTo sets the non nil settings on the instance.
+func (s *InstanceSettings) ApplyTo(instance *Instance) {
+	set := func(dst *bool, src *bool) {
+		if src != nil {
+			*dst = *src
+		}
+	}
+
+	set(&instance.RejectCall, s.RejectCall)
+	set(&instance.GroupsIgnore, s.GroupsIgnore)
+	set(&instance.AlwaysOnline, s.AlwaysOnline)
+	set(&instance.ReadMessages, s.ReadMessages)
+	set(&instance.ReadStatus, s.ReadStatus)
+	set(&instance.SyncFullHistory, s.SyncFullHistory)
+	set(&instance.SyncRecentHistory, s.SyncRecentHistory)
+	if s.MsgCall != nil {
+		instance.MsgCall = *s.MsgCall
+	}
+}
+
 type InstanceProxy struct {
 	ProxyHost     string `json:"proxyHost,omitempty"`
 	ProxyPort     string `json:"proxyPort,omitempty"`
@@ -30,4 +91,154 @@ type InstanceWebhook struct {
 	Base64   *bool             `json:"base64,omitempty"`
 	Headers  map[string]string `json:"headers,omitempty"`
 	Events   []string          `json:"events,omitempty"`
+	// EventUrls sends an event (keyed like Events, e.g. MESSAGES_UPSERT) to
+	// its own URL instead of Url or the ByEvents derived one.
+	EventUrls map[string]string `json:"eventUrls,omitempty"`
+
+	// Secret signs every delivery with HMAC-SHA256. When it is rotated the
+	// previous one keeps signing alongside it until PreviousSecretExpiresAt.
+	Secret                  string     `json:"secret,omitempty"`
+	PreviousSecret          string     `json:"previousSecret,omitempty"`
+	PreviousSecretExpiresAt *time.Time `json:"previousSecretExpiresAt,omitempty"`
+	// RemoveSecret asks an update to stop signing; it is never stored.
+	RemoveSecret bool `json:"-"`
+
+	Auth *InstanceWebhookAuth `json:"auth,omitempty"`
+
+	// RateLimit caps deliveries per second to each URL of this webhook,
+	// overriding WEBHOOK_RATE_LIMIT.
+	RateLimit float64 `json:"rateLimit,omitempty"`
+
+	// Ordered delivers the events of each chat in sequence, overriding
+	// WEBHOOK_ORDERED.
+	Ordered *bool `json:"ordered,omitempty"`
+
+	Retry *WebhookRetryPolicy `json:"retry,omitempty"`
+
+	// Batch sends the events as JSON arrays instead of one request each.
+	Batch *WebhookBatch `json:"batch,omitempty"`
+	// Gzip compresses request bodies larger than WEBHOOK_GZIP_MIN_SIZE.
+	Gzip *bool `json:"gzip,omitempty"`
+
+	// Template is a text/template that maps the event into the request body
+	// expected by the receiver. Empty sends the event as is.
+	Template *string `json:"template,omitempty"`
+}
+
+// WebhookBatch groups the events of a destination into arrays of up to Size
+// events, sent at the latest Interval milliseconds after the first one.
+type WebhookBatch struct {
+	Size     int `json:"size" validate:"min=0,max=1000"`
+	Interval int `json:"interval,omitempty" validate:"omitempty,min=0,max=60000"`
+}
+
+// RotateSecret replaces the signing secret. The old one keeps signing until
+// previousExpiresAt so receivers can roll over.
+func (w *InstanceWebhook) RotateSecret(secret string, previousExpiresAt *time.Time) {
+	if secret == "" || secret == w.Secret {
+		return
+	}
+
+	w.PreviousSecret = w.Secret
+	w.PreviousSecretExpiresAt = previousExpiresAt
+	if w.PreviousSecret == "" {
+		w.PreviousSecretExpiresAt = nil
+	}
+	w.Secret = secret
+}
+
+// Validate reports settings of the webhook that cannot be combined.
+func (w InstanceWebhook) Validate() error {
+	if w.Template != nil && *w.Template != "" && w.Batch != nil && w.Batch.Size > 1 {
+		return ErrBatchedTemplate
+	}
+
+	return nil
+}
+
+// Redacted is the webhook as the API returns it, without its signing secrets
+// and with its credentials masked.
+func (w InstanceWebhook) Redacted() InstanceWebhook {
+	w.Secret = ""
+	w.PreviousSecret = ""
+	w.Auth = w.Auth.Redacted()
+	return w
+}
+
+// ClearSecret stops signing deliveries, without a grace period for the
+// current secret.
+func (w *InstanceWebhook) ClearSecret() {
+	w.Secret = ""
+	w.PreviousSecret = ""
+	w.PreviousSecretExpiresAt = nil
+}
+
+type WebhookAuthType string
+
+const (
+	WebhookAuthBearer WebhookAuthType = "bearer"
+	WebhookAuthBasic  WebhookAuthType = "basic"
+	WebhookAuthOAuth2 WebhookAuthType = "oauth2" // client credentials grant
+)
+
+// InstanceWebhookAuth authenticates deliveries against receivers behind a
+// gateway. Only the fields of the selected Type are used.
+type InstanceWebhookAuth struct {
+	Type WebhookAuthType `json:"type,omitempty" validate:"omitempty,oneof=bearer basic oauth2"`
+
+	// bearer
+	Token string `json:"token,omitempty"`
+
+	// basic
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+
+	// oauth2
+	TokenURL       string            `json:"tokenUrl,omitempty" validate:"required_if=Type oauth2,omitempty,url"`
+	ClientID       string            `json:"clientId,omitempty"`
+	ClientSecret   string            `json:"clientSecret,omitempty"`
+	Scopes         []string          `json:"scopes,omitempty"`
+	EndpointParams map[string]string `json:"endpointParams,omitempty"`
+}
+
+// maskedCredential replaces the credentials returned by the API. Sent back in
+// an update, it keeps the stored value.
+const maskedCredential = "********"
+
+// Redacted is the auth block with its token, password and client secret masked.
+func (a *InstanceWebhookAuth) Redacted() *InstanceWebhookAuth {
+	if a == nil {
+		return nil
+	}
+
+	redacted := *a
+	redacted.Token = maskCredential(a.Token)
+	redacted.Password = maskCredential(a.Password)
+	redacted.ClientSecret = maskCredential(a.ClientSecret)
+	return &redacted
+}
+
+// KeepMasked puts back the credentials of old that came masked in the update,
+// so a block read from the API can be sent back as is.
+func (a *InstanceWebhookAuth) KeepMasked(old *InstanceWebhookAuth) {
+	if a == nil || old == nil {
+		return
+	}
+
+	if a.Token == maskedCredential {
+		a.Token = old.Token
+	}
+	if a.Password == maskedCredential {
+		a.Password = old.Password
+	}
+	if a.ClientSecret == maskedCredential {
+		a.ClientSecret = old.ClientSecret
+	}
+}
+
+func maskCredential(value string) string {
+	if value == "" {
+		return ""
+	}
+	return maskedCredential
 }
@@ -0,0 +1,80 @@
+package models
+
+import "time"
+
+// Poll is a poll sent or received by an instance, kept so the votes, which
+// only carry hashes of the options, can be resolved.
+type Poll struct {
+	ID              string    `json:"id"`
+	InstanceID      string    `json:"instanceId"`
+	RemoteJid       string    `json:"remoteJid"`
+	Creator         string    `json:"creator,omitempty"`
+	Name            string    `json:"name"`
+	Options         []string  `json:"options"`
+	SelectableCount int       `json:"selectableCount"`
+	CreatedAt       time.Time `json:"createdAt"`
+}
+
+// PollVote is the current selection of a voter; a new vote replaces it.
+type PollVote struct {
+	Voter    string    `json:"voter"`
+	VoterLid string    `json:"voterLid,omitempty"`
+	Options  []string  `json:"options"`
+	At       time.Time `json:"at"`
+}
+
+// Key identifies the voter across votes. The sender of a vote is reported by
+// phone number or by LID depending on the chat and the addressing mode, so the
+// LID is used whenever it is known and the phone number stays display data.
+func (v *PollVote) Key() string {
+	if v.VoterLid != "" {
+		return v.VoterLid
+	}
+
+	return v.Voter
+}
+
+type PollOptionResult struct {
+	Name   string   `json:"name"`
+	Votes  int      `json:"votes"`
+	Voters []string `json:"voters"`
+}
+
+type PollResults struct {
+	Poll        *Poll              `json:"poll"`
+	Results     []PollOptionResult `json:"results"`
+	TotalVoters int                `json:"totalVoters"`
+	Votes       []PollVote         `json:"votes"`
+}
+
+// Results tallies the votes per option, in the order of the poll options.
+func (p *Poll) Results(votes []PollVote) *PollResults {
+	results := make([]PollOptionResult, len(p.Options))
+	index := make(map[string]int, len(p.Options))
+	for i, option := range p.Options {
+		results[i] = PollOptionResult{Name: option, Voters: []string{}}
+		index[option] = i
+	}
+
+	voters := 0
+	for _, vote := range votes {
+		if len(vote.Options) == 0 {
+			continue
+		}
+
+		voters++
+		for _, option := range vote.Options {
+			if i, ok := index[option]; ok {
+				results[i].Votes++
+				results[i].Voters = append(results[i].Voters, vote.Voter)
+			}
+		}
+	}
+
+	return &PollResults{
+		Poll:        p,
+		Results:     results,
+		TotalVoters: voters,
+		Votes:       votes,
+	}
+}
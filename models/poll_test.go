@@ -0,0 +1,40 @@
+package models
+
+import "testing"
+
+func TestPollResults(t *testing.T) {
+	poll := &Poll{ID: "poll", Options: []string{"red", "green", "blue"}}
+	results := poll.Results([]PollVote{
+		{Voter: "a", Options: []string{"red"}},
+		{Voter: "b", Options: []string{"red", "blue"}},
+		{Voter: "c", Options: []string{}},
+		{Voter: "d", Options: []string{"unknown"}},
+	})
+
+	if results.TotalVoters != 3 {
+		t.Fatalf("expected 3 voters, got %d", results.TotalVoters)
+	}
+
+	expected := map[string]int{"red": 2, "green": 0, "blue": 1}
+	for i, result := range results.Results {
+		if result.Name != poll.Options[i] {
+			t.Fatalf("expected option %q at %d, got %q", poll.Options[i], i, result.Name)
+		}
+		if result.Votes != expected[result.Name] || len(result.Voters) != result.Votes {
+			t.Fatalf("unexpected tally for %q: %+v", result.Name, result)
+		}
+	}
+}
+
+func TestPollVoteKey(t *testing.T) {
+	byPhone := PollVote{Voter: "5511999999999@s.whatsapp.net", VoterLid: "123@lid"}
+	byLid := PollVote{Voter: "123@lid", VoterLid: "123@lid"}
+	if byPhone.Key() != byLid.Key() {
+		t.Fatalf("expected the same key for one voter, got %q and %q", byPhone.Key(), byLid.Key())
+	}
+
+	unknown := PollVote{Voter: "5511999999999@s.whatsapp.net"}
+	if unknown.Key() != unknown.Voter {
+		t.Fatalf("expected the phone number without a LID, got %q", unknown.Key())
+	}
+}
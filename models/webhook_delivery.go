@@ -0,0 +1,67 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// WebhookDelivery is one attempt to deliver an event to a webhook.
+type WebhookDelivery struct {
+	InstanceID   string    `json:"instanceId"`
+	Subscription string    `json:"subscription,omitempty"` // empty for the main webhook
+	Event        string    `json:"event"`
+	MessageID    string    `json:"messageId,omitempty"`
+	Url          string    `json:"url"`
+	StatusCode   int       `json:"statusCode,omitempty"`
+	Success      bool      `json:"success"`
+	LatencyMs    int64     `json:"latencyMs"`
+	Attempt      int       `json:"attempt"`
+	Error        string    `json:"error,omitempty"` // error or response snippet of failed attempts
+	At           time.Time `json:"at"`
+}
+
+// WebhookDeliveryFilter narrows a delivery log listing. Zero fields match
+// everything.
+type WebhookDeliveryFilter struct {
+	Event        string // MESSAGES_UPSERT or messages.upsert
+	Subscription string
+	MessageID    string
+	Success      *bool
+	StatusCode   int
+	From         time.Time
+	To           time.Time
+	Limit        int
+}
+
+// WebhookDeliveryStats are the running counters of the deliveries of an
+// instance.
+type WebhookDeliveryStats struct {
+	Total         int64      `json:"total"`
+	Success       int64      `json:"success"`
+	Failure       int64      `json:"failure"`
+	AvgLatencyMs  float64    `json:"avgLatencyMs"`
+	MaxLatencyMs  int64      `json:"maxLatencyMs"`
+	LastSuccessAt *time.Time `json:"lastSuccessAt,omitempty"`
+	LastFailureAt *time.Time `json:"lastFailureAt,omitempty"`
+}
+
+// SuccessRate is the percentage of successful attempts, 0 without any.
+func (s *WebhookDeliveryStats) SuccessRate() float64 {
+	if s.Total == 0 {
+		return 0
+	}
+
+	return float64(s.Success) * 100 / float64(s.Total)
+}
+
+// WebhookTestResult is the outcome of a test fired event.
+type WebhookTestResult struct {
+	Event      string          `json:"event"`
+	Url        string          `json:"url"`
+	Success    bool            `json:"success"`
+	StatusCode int             `json:"statusCode,omitempty"`
+	Response   string          `json:"response,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	LatencyMs  int64           `json:"latencyMs"`
+	Payload    json.RawMessage `json:"payload"`
+}
@@ -0,0 +1,28 @@
+package models
+
+type WebhookBackoff string
+
+const (
+	WebhookBackoffFixed       WebhookBackoff = "fixed"
+	WebhookBackoffExponential WebhookBackoff = "exponential"
+	WebhookBackoffJitter      WebhookBackoff = "jitter" // exponential with full jitter
+)
+
+// WebhookRetryPolicy tunes how failed deliveries of a webhook are retried.
+// Unset fields keep the defaults: 2 retries, exponential backoff from 1s,
+// a 10s timeout and retries on network errors, 429 and 5xx.
+type WebhookRetryPolicy struct {
+	// MaxRetries is the number of retries after the first attempt; 0 disables
+	// retries.
+	MaxRetries *int           `json:"maxRetries,omitempty" validate:"omitempty,min=0,max=100"`
+	Backoff    WebhookBackoff `json:"backoff,omitempty" validate:"omitempty,oneof=fixed exponential jitter"`
+	// InitialInterval is the delay before the first retry, in seconds.
+	InitialInterval int `json:"initialInterval,omitempty" validate:"omitempty,min=0"`
+	// MaxInterval caps the delay between retries, in seconds.
+	MaxInterval int `json:"maxInterval,omitempty" validate:"omitempty,min=0"`
+	// Timeout bounds each attempt, in seconds.
+	Timeout int `json:"timeout,omitempty" validate:"omitempty,min=1,max=30"`
+	// RetryStatuses replaces the response codes that are retried. Network
+	// errors are always retried.
+	RetryStatuses []int `json:"retryStatuses,omitempty" validate:"omitempty,dive,min=100,max=599"`
+}
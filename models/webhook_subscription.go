@@ -0,0 +1,23 @@
+package models
+
+// WebhookSubscription is an extra webhook target of an instance. It takes the
+// same delivery settings as the main webhook plus chat filters, which only
+// apply to events tied to a chat (messages, receipts, deletions).
+type WebhookSubscription struct {
+	ID string `json:"id"`
+	InstanceWebhook
+
+	// IncludeJids restricts deliveries to these chats, ExcludeJids drops them.
+	// Entries may be a full JID, a bare number, or a server such as "@g.us".
+	IncludeJids []string `json:"includeJids,omitempty"`
+	ExcludeJids []string `json:"excludeJids,omitempty"`
+	// FromMe keeps only our own messages (true) or only received ones (false).
+	FromMe *bool `json:"fromMe,omitempty"`
+}
+
+// Redacted is the subscription as the API returns it, see
+// InstanceWebhook.Redacted.
+func (s WebhookSubscription) Redacted() WebhookSubscription {
+	s.InstanceWebhook = s.InstanceWebhook.Redacted()
+	return s
+}
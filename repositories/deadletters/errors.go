@@ -0,0 +1,8 @@
+package deadletters
+
+import "errors"
+
+// redis
+var (
+	ErrInstanceIDEmpty = errors.New("dead letter InstanceID cannot be empty")
+)
@@ -0,0 +1,215 @@
+package deadletters
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/verbeux-ai/whatsmiau/interfaces"
+	"github.com/verbeux-ai/whatsmiau/models"
+	"golang.org/x/net/context"
+)
+
+// These verify if RedisDeadLetter follows dead letter interface pattern
+var _ interfaces.DeadLetterRepository = (*RedisDeadLetter)(nil)
+
+var ErrorNotFound = errors.New("not found")
+
+// RedisDeadLetter keeps, per instance, a sorted set of dead letter ids scored
+// by failure time (for range queries) and a hash with the letters themselves.
+type RedisDeadLetter struct {
+	db     *redis.Client
+	maxLen int64
+}
+
+func (s *RedisDeadLetter) indexKey(instanceID string) string {
+	return fmt.Sprintf("dead_letter_%s", instanceID)
+}
+
+func (s *RedisDeadLetter) dataKey(instanceID string) string {
+	return fmt.Sprintf("dead_letter_data_%s", instanceID)
+}
+
+// NewRedis creates the repository. maxLen bounds how many letters are kept per
+// instance; the oldest ones are dropped first. Zero means unbounded.
+func NewRedis(client *redis.Client, maxLen int64) *RedisDeadLetter {
+	return &RedisDeadLetter{
+		db:     client,
+		maxLen: maxLen,
+	}
+}
+
+func (s *RedisDeadLetter) Create(ctx context.Context, letter *models.DeadLetter) error {
+	if letter.InstanceID == "" {
+		return ErrInstanceIDEmpty
+	}
+	if letter.ID == "" {
+		letter.ID = uuid.NewString()
+	}
+	if letter.FailedAt.IsZero() {
+		letter.FailedAt = time.Now()
+	}
+
+	data, err := json.Marshal(letter)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.db.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZAdd(ctx, s.indexKey(letter.InstanceID), &redis.Z{
+			Score:  float64(letter.FailedAt.UnixMilli()),
+			Member: letter.ID,
+		})
+		pipe.HSet(ctx, s.dataKey(letter.InstanceID), letter.ID, data)
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	return s.trim(ctx, letter.InstanceID)
+}
+
+func (s *RedisDeadLetter) trim(ctx context.Context, instanceID string) error {
+	if s.maxLen <= 0 {
+		return nil
+	}
+
+	total, err := s.db.ZCard(ctx, s.indexKey(instanceID)).Result()
+	if err != nil {
+		return err
+	}
+	if total <= s.maxLen {
+		return nil
+	}
+
+	oldest, err := s.db.ZPopMin(ctx, s.indexKey(instanceID), total-s.maxLen).Result()
+	if err != nil {
+		return err
+	}
+
+	ids := make([]string, 0, len(oldest))
+	for _, z := range oldest {
+		if id, ok := z.Member.(string); ok {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	return s.db.HDel(ctx, s.dataKey(instanceID), ids...).Err()
+}
+
+func (s *RedisDeadLetter) List(ctx context.Context, instanceID string, from, to time.Time, limit int64) ([]models.DeadLetter, error) {
+	if instanceID == "" {
+		return nil, ErrInstanceIDEmpty
+	}
+
+	ids, err := s.idsBetween(ctx, instanceID, from, to, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ids) == 0 {
+		return []models.DeadLetter{}, nil
+	}
+
+	rawVals, err := s.db.HMGet(ctx, s.dataKey(instanceID), ids...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	letters := make([]models.DeadLetter, 0, len(rawVals))
+	for _, raw := range rawVals {
+		strVal, ok := raw.(string)
+		if !ok {
+			continue
+		}
+		var letter models.DeadLetter
+		if err := json.Unmarshal([]byte(strVal), &letter); err != nil {
+			continue
+		}
+		letters = append(letters, letter)
+	}
+
+	return letters, nil
+}
+
+func (s *RedisDeadLetter) Get(ctx context.Context, instanceID, id string) (*models.DeadLetter, error) {
+	if instanceID == "" {
+		return nil, ErrInstanceIDEmpty
+	}
+
+	raw, err := s.db.HGet(ctx, s.dataKey(instanceID), id).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, ErrorNotFound
+		}
+		return nil, err
+	}
+
+	var letter models.DeadLetter
+	if err := json.Unmarshal([]byte(raw), &letter); err != nil {
+		return nil, err
+	}
+
+	return &letter, nil
+}
+
+func (s *RedisDeadLetter) Delete(ctx context.Context, instanceID string, ids ...string) error {
+	if instanceID == "" {
+		return ErrInstanceIDEmpty
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	members := make([]any, 0, len(ids))
+	for _, id := range ids {
+		members = append(members, id)
+	}
+
+	_, err := s.db.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRem(ctx, s.indexKey(instanceID), members...)
+		pipe.HDel(ctx, s.dataKey(instanceID), ids...)
+		return nil
+	})
+	return err
+}
+
+func (s *RedisDeadLetter) Purge(ctx context.Context, instanceID string, from, to time.Time) (int64, error) {
+	if instanceID == "" {
+		return 0, ErrInstanceIDEmpty
+	}
+
+	ids, err := s.idsBetween(ctx, instanceID, from, to, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := s.Delete(ctx, instanceID, ids...); err != nil {
+		return 0, err
+	}
+
+	return int64(len(ids)), nil
+}
+
+func (s *RedisDeadLetter) idsBetween(ctx context.Context, instanceID string, from, to time.Time, limit int64) ([]string, error) {
+	min, max := "-inf", "+inf"
+	if !from.IsZero() {
+		min = strconv.FormatInt(from.UnixMilli(), 10)
+	}
+	if !to.IsZero() {
+		max = strconv.FormatInt(to.UnixMilli(), 10)
+	}
+
+	return s.db.ZRangeByScore(ctx, s.indexKey(instanceID), &redis.ZRangeBy{
+		Min:   min,
+		Max:   max,
+		Count: limit,
+	}).Result()
+}
@@ -0,0 +1,8 @@
+package deliveries
+
+import "errors"
+
+// redis
+var (
+	ErrInstanceIDEmpty = errors.New("webhook delivery InstanceID cannot be empty")
+)
@@ -0,0 +1,199 @@
+package deliveries
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/verbeux-ai/whatsmiau/interfaces"
+	"github.com/verbeux-ai/whatsmiau/models"
+	"golang.org/x/net/context"
+)
+
+// These verify if RedisDelivery follows webhook delivery interface pattern
+var _ interfaces.WebhookDeliveryRepository = (*RedisDelivery)(nil)
+
+// RedisDelivery keeps, per instance, a capped list of the latest delivery
+// attempts (newest first) and a hash with the running counters.
+type RedisDelivery struct {
+	db     *redis.Client
+	maxLen int64
+}
+
+func (s *RedisDelivery) logKey(instanceID string) string {
+	return fmt.Sprintf("webhook_delivery_%s", instanceID)
+}
+
+func (s *RedisDelivery) statsKey(instanceID string) string {
+	return fmt.Sprintf("webhook_delivery_stats_%s", instanceID)
+}
+
+// NewRedis creates the repository. maxLen bounds how many attempts are kept
+// per instance; the oldest ones are dropped first. Zero means unbounded.
+func NewRedis(client *redis.Client, maxLen int64) *RedisDelivery {
+	return &RedisDelivery{
+		db:     client,
+		maxLen: maxLen,
+	}
+}
+
+// recordScript appends to the log and updates the counters in one step, so
+// the maximum latency is kept right under concurrent workers.
+var recordScript = redis.NewScript(`
+redis.call('LPUSH', KEYS[1], ARGV[1])
+if tonumber(ARGV[2]) > 0 then
+	redis.call('LTRIM', KEYS[1], 0, tonumber(ARGV[2]) - 1)
+end
+local latency = tonumber(ARGV[4])
+redis.call('HINCRBY', KEYS[2], 'total', 1)
+redis.call('HINCRBY', KEYS[2], 'latency_ms', latency)
+if ARGV[3] == '1' then
+	redis.call('HINCRBY', KEYS[2], 'success', 1)
+	redis.call('HSET', KEYS[2], 'last_success_at', ARGV[5])
+else
+	redis.call('HINCRBY', KEYS[2], 'failure', 1)
+	redis.call('HSET', KEYS[2], 'last_failure_at', ARGV[5])
+end
+local max = tonumber(redis.call('HGET', KEYS[2], 'max_latency_ms') or '0')
+if latency > max then
+	redis.call('HSET', KEYS[2], 'max_latency_ms', latency)
+end
+return 1
+`)
+
+func (s *RedisDelivery) Record(ctx context.Context, delivery *models.WebhookDelivery) error {
+	if delivery.InstanceID == "" {
+		return ErrInstanceIDEmpty
+	}
+	if delivery.At.IsZero() {
+		delivery.At = time.Now()
+	}
+
+	data, err := json.Marshal(delivery)
+	if err != nil {
+		return err
+	}
+
+	success := "0"
+	if delivery.Success {
+		success = "1"
+	}
+
+	return recordScript.Run(ctx, s.db,
+		[]string{s.logKey(delivery.InstanceID), s.statsKey(delivery.InstanceID)},
+		data, s.maxLen, success, delivery.LatencyMs, delivery.At.UnixMilli(),
+	).Err()
+}
+
+func (s *RedisDelivery) List(ctx context.Context, instanceID string, filter models.WebhookDeliveryFilter) ([]models.WebhookDelivery, error) {
+	if instanceID == "" {
+		return nil, ErrInstanceIDEmpty
+	}
+
+	rawVals, err := s.db.LRange(ctx, s.logKey(instanceID), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	deliveries := make([]models.WebhookDelivery, 0)
+	for _, raw := range rawVals {
+		var delivery models.WebhookDelivery
+		if err := json.Unmarshal([]byte(raw), &delivery); err != nil {
+			continue
+		}
+		if !matches(&delivery, &filter) {
+			continue
+		}
+
+		deliveries = append(deliveries, delivery)
+		if filter.Limit > 0 && len(deliveries) >= filter.Limit {
+			break
+		}
+	}
+
+	return deliveries, nil
+}
+
+func matches(delivery *models.WebhookDelivery, filter *models.WebhookDeliveryFilter) bool {
+	if filter.Event != "" && eventName(delivery.Event) != eventName(filter.Event) {
+		return false
+	}
+	if filter.Subscription != "" && delivery.Subscription != filter.Subscription {
+		return false
+	}
+	if filter.MessageID != "" && delivery.MessageID != filter.MessageID {
+		return false
+	}
+	if filter.Success != nil && delivery.Success != *filter.Success {
+		return false
+	}
+	if filter.StatusCode != 0 && delivery.StatusCode != filter.StatusCode {
+		return false
+	}
+	if !filter.From.IsZero() && delivery.At.Before(filter.From) {
+		return false
+	}
+	if !filter.To.IsZero() && delivery.At.After(filter.To) {
+		return false
+	}
+
+	return true
+}
+
+var eventNameReplacer = strings.NewReplacer(".", "_", "-", "_")
+
+// eventName accepts both the config (MESSAGES_UPSERT) and the payload
+// (messages.upsert) spelling of an event.
+func eventName(event string) string {
+	return strings.ToUpper(eventNameReplacer.Replace(event))
+}
+
+func (s *RedisDelivery) Stats(ctx context.Context, instanceID string) (*models.WebhookDeliveryStats, error) {
+	if instanceID == "" {
+		return nil, ErrInstanceIDEmpty
+	}
+
+	values, err := s.db.HGetAll(ctx, s.statsKey(instanceID)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	number := func(field string) int64 {
+		n, _ := strconv.ParseInt(values[field], 10, 64)
+		return n
+	}
+	timestamp := func(field string) *time.Time {
+		ms := number(field)
+		if ms == 0 {
+			return nil
+		}
+		t := time.UnixMilli(ms)
+		return &t
+	}
+
+	stats := &models.WebhookDeliveryStats{
+		Total:         number("total"),
+		Success:       number("success"),
+		Failure:       number("failure"),
+		MaxLatencyMs:  number("max_latency_ms"),
+		LastSuccessAt: timestamp("last_success_at"),
+		LastFailureAt: timestamp("last_failure_at"),
+	}
+	if stats.Total > 0 {
+		stats.AvgLatencyMs = float64(number("latency_ms")) / float64(stats.Total)
+	}
+
+	return stats, nil
+}
+
+// Reset clears the log and the counters of an instance.
+func (s *RedisDelivery) Reset(ctx context.Context, instanceID string) error {
+	if instanceID == "" {
+		return ErrInstanceIDEmpty
+	}
+
+	return s.db.Del(ctx, s.logKey(instanceID), s.statsKey(instanceID)).Err()
+}
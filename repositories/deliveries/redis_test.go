@@ -0,0 +1,42 @@
+package deliveries
+
+import (
+	"testing"
+	"time"
+
+	"github.com/verbeux-ai/whatsmiau/models"
+)
+
+func TestMatches(t *testing.T) {
+	at := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	delivery := &models.WebhookDelivery{
+		Event:      "messages.upsert",
+		MessageID:  "ABC",
+		StatusCode: 500,
+		At:         at,
+	}
+
+	success, failure := true, false
+	tests := []struct {
+		name   string
+		filter models.WebhookDeliveryFilter
+		want   bool
+	}{
+		{"empty", models.WebhookDeliveryFilter{}, true},
+		{"config event name", models.WebhookDeliveryFilter{Event: "MESSAGES_UPSERT"}, true},
+		{"payload event name", models.WebhookDeliveryFilter{Event: "messages.upsert"}, true},
+		{"other event", models.WebhookDeliveryFilter{Event: "MESSAGES_UPDATE"}, false},
+		{"message", models.WebhookDeliveryFilter{MessageID: "ABC"}, true},
+		{"failures", models.WebhookDeliveryFilter{Success: &failure}, true},
+		{"successes", models.WebhookDeliveryFilter{Success: &success}, false},
+		{"status code", models.WebhookDeliveryFilter{StatusCode: 404}, false},
+		{"range", models.WebhookDeliveryFilter{From: at.Add(-time.Minute), To: at.Add(time.Minute)}, true},
+		{"after range", models.WebhookDeliveryFilter{To: at.Add(-time.Minute)}, false},
+	}
+
+	for _, tt := range tests {
+		if got := matches(delivery, &tt.filter); got != tt.want {
+			t.Errorf("%s: matches = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
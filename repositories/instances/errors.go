@@ -4,5 +4,6 @@ import "errors"
 
 // redis
 var (
-	ErrInstanceIDEmpty = errors.New("instance InstanceID cannot be empty")
+	ErrInstanceIDEmpty  = errors.New("instance InstanceID cannot be empty")
+	ErrConcurrentUpdate = errors.New("instance was updated concurrently, try again")
 )
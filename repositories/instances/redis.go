@@ -57,16 +57,14 @@ func (s *RedisInstance) Update(ctx context.Context, id string, toUpdate *models.
 		return nil, ErrInstanceIDEmpty
 	}
 
-	result, err := s.List(ctx, id)
-	if err != nil {
-		return nil, err
-	}
-
-	if len(result) <= 0 {
-		return nil, ErrorNotFound
-	}
+	return s.modify(ctx, id, func(oldInstance *models.Instance) error {
+		mergeInstance(oldInstance, toUpdate)
+		return oldInstance.Webhook.Validate()
+	})
+}
 
-	oldInstance := result[0]
+// mergeInstance applies the fields set in toUpdate.
+func mergeInstance(oldInstance, toUpdate *models.Instance) {
 	if len(toUpdate.RemoteJID) > 0 {
 		oldInstance.RemoteJID = toUpdate.RemoteJID
 	}
@@ -93,6 +91,68 @@ func (s *RedisInstance) Update(ctx context.Context, id string, toUpdate *models.
 	if toUpdate.Webhook.Events != nil {
 		oldInstance.Webhook.Events = toUpdate.Webhook.Events
 	}
+	if toUpdate.Webhook.EventUrls != nil {
+		if oldInstance.Webhook.EventUrls == nil {
+			oldInstance.Webhook.EventUrls = map[string]string{}
+		}
+		for k, v := range toUpdate.Webhook.EventUrls {
+			if v == "" {
+				delete(oldInstance.Webhook.EventUrls, k)
+				continue
+			}
+			oldInstance.Webhook.EventUrls[k] = v
+		}
+	}
+	if toUpdate.Webhook.RemoveSecret {
+		oldInstance.Webhook.ClearSecret()
+	} else {
+		oldInstance.Webhook.RotateSecret(toUpdate.Webhook.Secret, toUpdate.Webhook.PreviousSecretExpiresAt)
+	}
+	if toUpdate.Webhook.Auth != nil {
+		toUpdate.Webhook.Auth.KeepMasked(oldInstance.Webhook.Auth)
+		oldInstance.Webhook.Auth = toUpdate.Webhook.Auth
+		if oldInstance.Webhook.Auth.Type == "" {
+			oldInstance.Webhook.Auth = nil // empty auth block removes authentication
+		}
+	}
+
+	if toUpdate.Webhook.RateLimit > 0 {
+		oldInstance.Webhook.RateLimit = toUpdate.Webhook.RateLimit
+	} else if toUpdate.Webhook.RateLimit < 0 {
+		oldInstance.Webhook.RateLimit = 0 // negative falls back to the global limit
+	}
+	if toUpdate.Webhook.Ordered != nil {
+		oldInstance.Webhook.Ordered = toUpdate.Webhook.Ordered
+	}
+	if toUpdate.Webhook.Retry != nil {
+		oldInstance.Webhook.Retry = toUpdate.Webhook.Retry
+	}
+	if toUpdate.Webhook.Batch != nil {
+		oldInstance.Webhook.Batch = toUpdate.Webhook.Batch
+		if oldInstance.Webhook.Batch.Size == 0 {
+			oldInstance.Webhook.Batch = nil // size 0 goes back to one request per event
+		}
+	}
+	if toUpdate.Webhook.Gzip != nil {
+		oldInstance.Webhook.Gzip = toUpdate.Webhook.Gzip
+	}
+	if toUpdate.Webhook.Template != nil {
+		oldInstance.Webhook.Template = toUpdate.Webhook.Template
+		if *oldInstance.Webhook.Template == "" {
+			oldInstance.Webhook.Template = nil // empty template sends the event as is
+		}
+	}
+
+	if toUpdate.Subscriptions != nil {
+		oldInstance.Subscriptions = toUpdate.Subscriptions
+	}
+	if toUpdate.Sink != nil {
+		toUpdate.Sink.KeepMasked(oldInstance.Sink)
+		oldInstance.Sink = toUpdate.Sink
+		if oldInstance.Sink.Type == "" {
+			oldInstance.Sink = nil // empty sink type removes the sink
+		}
+	}
 
 	if toUpdate.ProxyHost != "" {
 		oldInstance.InstanceProxy = toUpdate.InstanceProxy
@@ -112,13 +172,95 @@ func (s *RedisInstance) Update(ctx context.Context, id string, toUpdate *models.
 			oldInstance.ProxyPassword = toUpdate.ProxyPassword
 		}
 	}
+}
 
-	data, err := json.Marshal(oldInstance)
-	if err != nil {
-		return nil, err
+// UpdateSettings applies a partial settings update. Unlike Update, it can set
+// them back to false or empty.
+func (s *RedisInstance) UpdateSettings(ctx context.Context, id string, settings *models.InstanceSettings) (*models.Instance, error) {
+	if id == "" {
+		return nil, ErrInstanceIDEmpty
+	}
+
+	return s.modify(ctx, id, func(instance *models.Instance) error {
+		settings.ApplyTo(instance)
+		return nil
+	})
+}
+
+// UpdateSubscriptions replaces the subscriptions of an instance with the ones
+// update derives from its current state. Errors of update are returned as is.
+func (s *RedisInstance) UpdateSubscriptions(ctx context.Context, id string, update func(instance *models.Instance) ([]models.WebhookSubscription, error)) (*models.Instance, error) {
+	if id == "" {
+		return nil, ErrInstanceIDEmpty
+	}
+
+	return s.modify(ctx, id, func(instance *models.Instance) error {
+		subscriptions, err := update(instance)
+		if err != nil {
+			return err
+		}
+
+		if subscriptions == nil {
+			subscriptions = []models.WebhookSubscription{}
+		}
+		instance.Subscriptions = subscriptions
+		return nil
+	})
+}
+
+// maxUpdateAttempts bounds the retries of modify when the instance keeps
+// changing under it.
+const maxUpdateAttempts = 5
+
+// modify saves the changes apply makes to the stored instance. The instance is
+// watched meanwhile, so concurrent updates are never lost: apply runs again on
+// the newer state instead.
+func (s *RedisInstance) modify(ctx context.Context, id string, apply func(instance *models.Instance) error) (*models.Instance, error) {
+	key := s.key(id)
+	var instance models.Instance
+	txf := func(tx *redis.Tx) error {
+		raw, err := tx.Get(ctx, key).Bytes()
+		if err != nil {
+			if errors.Is(err, redis.Nil) {
+				return ErrorNotFound
+			}
+			return err
+		}
+
+		instance = models.Instance{}
+		if err := json.Unmarshal(raw, &instance); err != nil {
+			return err
+		}
+
+		if err := apply(&instance); err != nil {
+			return err
+		}
+
+		data, err := json.Marshal(instance)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, key, data, redis.KeepTTL)
+			return nil
+		})
+		return err
+	}
+
+	for range maxUpdateAttempts {
+		err := s.db.Watch(ctx, txf, key)
+		if errors.Is(err, redis.TxFailedErr) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return &instance, nil
 	}
 
-	return &oldInstance, s.db.Set(ctx, s.key(id), data, redis.KeepTTL).Err()
+	return nil, ErrConcurrentUpdate
 }
 
 func (s *RedisInstance) List(ctx context.Context, id string) ([]models.Instance, error) {
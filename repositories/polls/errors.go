@@ -0,0 +1,9 @@
+package polls
+
+import "errors"
+
+// redis
+var (
+	ErrInstanceIDEmpty = errors.New("poll InstanceID cannot be empty")
+	ErrorNotFound      = errors.New("poll not found")
+)
@@ -0,0 +1,129 @@
+package polls
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/verbeux-ai/whatsmiau/interfaces"
+	"github.com/verbeux-ai/whatsmiau/models"
+	"golang.org/x/net/context"
+)
+
+// These verify if RedisPoll follows poll interface pattern
+var _ interfaces.PollRepository = (*RedisPoll)(nil)
+
+// RedisPoll keeps each poll as JSON and its votes in a hash keyed by voter,
+// see models.PollVote.Key.
+// Both expire retention after the last change.
+type RedisPoll struct {
+	db        *redis.Client
+	retention time.Duration
+}
+
+func (s *RedisPoll) pollKey(instanceID, pollID string) string {
+	return fmt.Sprintf("poll_%s_%s", instanceID, pollID)
+}
+
+func (s *RedisPoll) votesKey(instanceID, pollID string) string {
+	return fmt.Sprintf("poll_votes_%s_%s", instanceID, pollID)
+}
+
+func NewRedis(client *redis.Client, retention time.Duration) *RedisPoll {
+	return &RedisPoll{
+		db:        client,
+		retention: retention,
+	}
+}
+
+func (s *RedisPoll) Save(ctx context.Context, poll *models.Poll) error {
+	if poll.InstanceID == "" {
+		return ErrInstanceIDEmpty
+	}
+
+	data, err := json.Marshal(poll)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Set(ctx, s.pollKey(poll.InstanceID, poll.ID), data, s.retention).Err()
+}
+
+func (s *RedisPoll) Get(ctx context.Context, instanceID, pollID string) (*models.Poll, error) {
+	if instanceID == "" {
+		return nil, ErrInstanceIDEmpty
+	}
+
+	data, err := s.db.Get(ctx, s.pollKey(instanceID, pollID)).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, ErrorNotFound
+		}
+		return nil, err
+	}
+
+	var poll models.Poll
+	if err := json.Unmarshal(data, &poll); err != nil {
+		return nil, err
+	}
+
+	return &poll, nil
+}
+
+func (s *RedisPoll) Vote(ctx context.Context, instanceID, pollID string, vote *models.PollVote) error {
+	if instanceID == "" {
+		return ErrInstanceIDEmpty
+	}
+
+	key := s.votesKey(instanceID, pollID)
+	pipe := s.db.TxPipeline()
+	// a vote recorded before the LID of the voter was known is replaced too
+	if vote.Key() != vote.Voter {
+		pipe.HDel(ctx, key, vote.Voter)
+	}
+	if len(vote.Options) == 0 {
+		pipe.HDel(ctx, key, vote.Key())
+	} else {
+		data, err := json.Marshal(vote)
+		if err != nil {
+			return err
+		}
+		pipe.HSet(ctx, key, vote.Key(), data)
+	}
+	if s.retention > 0 {
+		pipe.Expire(ctx, key, s.retention)
+		pipe.Expire(ctx, s.pollKey(instanceID, pollID), s.retention)
+	}
+
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Votes returns the current votes, oldest first.
+func (s *RedisPoll) Votes(ctx context.Context, instanceID, pollID string) ([]models.PollVote, error) {
+	if instanceID == "" {
+		return nil, ErrInstanceIDEmpty
+	}
+
+	values, err := s.db.HGetAll(ctx, s.votesKey(instanceID, pollID)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	votes := make([]models.PollVote, 0, len(values))
+	for _, raw := range values {
+		var vote models.PollVote
+		if err := json.Unmarshal([]byte(raw), &vote); err != nil {
+			continue
+		}
+		votes = append(votes, vote)
+	}
+	sort.Slice(votes, func(i, j int) bool {
+		return votes[i].At.Before(votes[j].At)
+	})
+
+	return votes, nil
+}
@@ -147,6 +147,48 @@ func (s *Chat) SendChatPresence(ctx echo.Context) error {
 	return ctx.JSON(http.StatusOK, map[string]interface{}{})
 }
 
+// SubscribePresence godoc
+// @Summary      Subscribe to a contact's presence
+// @Description  Subscribes to the online and typing updates of a contact, which are delivered as PRESENCE_UPDATE events. WhatsApp only sends them while the instance is available, and the subscription is lost when the instance reconnects.
+// @Tags         Chat
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        instance  path      string                        true  "Instance ID"
+// @Param        body      body      dto.SubscribePresenceRequest  true  "Contact to subscribe to"
+// @Success      200       {object}  map[string]interface{}        "Empty object on success"
+// @Failure      400       {object}  utils.HTTPErrorResponse
+// @Failure      422       {object}  utils.HTTPErrorResponse
+// @Failure      500       {object}  utils.HTTPErrorResponse
+// @Router       /instance/{instance}/chat/subscribe-presence [post]
+// @Router       /chat/subscribePresence/{instance} [post]
+func (s *Chat) SubscribePresence(ctx echo.Context) error {
+	var request dto.SubscribePresenceRequest
+	if err := ctx.Bind(&request); err != nil {
+		return utils.HTTPFail(ctx, http.StatusUnprocessableEntity, err, "failed to bind request body")
+	}
+
+	if err := validator.New().Struct(&request); err != nil {
+		return utils.HTTPFail(ctx, http.StatusBadRequest, err, "invalid request body")
+	}
+
+	number, err := numberToJid(request.Number)
+	if err != nil {
+		zap.L().Error("error converting number to jid", zap.Error(err))
+		return utils.HTTPFail(ctx, http.StatusBadRequest, err, "invalid number format")
+	}
+
+	if err := s.whatsmiau.SubscribePresence(ctx.Request().Context(), &whatsmiau.SubscribePresenceRequest{
+		InstanceID: request.InstanceID,
+		RemoteJID:  number,
+	}); err != nil {
+		zap.L().Error("Whatsmiau.SubscribePresence failed", zap.Error(err))
+		return utils.HTTPFail(ctx, http.StatusInternalServerError, err, "Whatsmiau.SubscribePresence failed")
+	}
+
+	return ctx.JSON(http.StatusOK, map[string]interface{}{})
+}
+
 // NumberExists godoc
 // @Summary      Check if numbers exist on WhatsApp
 // @Description  Checks whether the given phone numbers are registered on WhatsApp
@@ -0,0 +1,161 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/verbeux-ai/whatsmiau/interfaces"
+	"github.com/verbeux-ai/whatsmiau/lib/whatsmiau"
+	"github.com/verbeux-ai/whatsmiau/server/dto"
+	"github.com/verbeux-ai/whatsmiau/utils"
+	"go.uber.org/zap"
+	"golang.org/x/net/websocket"
+)
+
+const streamWriteTimeout = 10 * time.Second
+
+// streamPingInterval keeps idle streams from being dropped by proxies and
+// finds dead peers, whose writes then fail.
+var streamPingInterval = 30 * time.Second
+
+// eventSubscription is what the stream reads of a whatsmiau.EventSubscription.
+type eventSubscription interface {
+	C() <-chan []byte
+	Done() <-chan struct{}
+	Overflowed() bool
+	SetEvents(events []string)
+	Close()
+}
+
+type Events struct {
+	repo      interfaces.InstanceRepository
+	subscribe func(instance string, events []string, base64 bool) eventSubscription
+}
+
+func NewEvents(repository interfaces.InstanceRepository, whatsmiau *whatsmiau.Whatsmiau) *Events {
+	return &Events{
+		repo: repository,
+		subscribe: func(instance string, events []string, base64 bool) eventSubscription {
+			return whatsmiau.SubscribeEvents(instance, events, base64)
+		},
+	}
+}
+
+// Stream godoc
+// @Summary      Stream events over WebSocket
+// @Description  Upgrades to a WebSocket that receives the same payloads as the webhook. Without an instance every instance and the application events are streamed. Browsers may pass the API key as the apikey query parameter. Send {"events": [...]} to change the filter, malformed frames are answered with an error and ignored; consumers that fall behind are disconnected.
+// @Tags         Events
+// @Security     ApiKeyAuth
+// @Param        instance  path   string  false  "Instance ID"
+// @Param        events    query  string  false  "Comma separated events, e.g. MESSAGES_UPSERT,CONNECTION_UPDATE"
+// @Param        base64    query  bool    false  "Include media as base64"
+// @Success      101
+// @Failure      404  {object}  utils.HTTPErrorResponse
+// @Failure      422  {object}  utils.HTTPErrorResponse
+// @Failure      500  {object}  utils.HTTPErrorResponse
+// @Router       /instance/{instance}/events [get]
+// @Router       /events [get]
+func (s *Events) Stream(ctx echo.Context) error {
+	var request dto.StreamEventsRequest
+	if err := ctx.Bind(&request); err != nil {
+		return utils.HTTPFail(ctx, http.StatusUnprocessableEntity, err, "failed to bind request body")
+	}
+
+	if request.InstanceID != "" {
+		result, err := s.repo.List(ctx.Request().Context(), request.InstanceID)
+		if err != nil {
+			zap.L().Error("failed to list instances", zap.Error(err))
+			return utils.HTTPFail(ctx, http.StatusInternalServerError, err, "failed to list instances")
+		}
+
+		if len(result) == 0 {
+			return utils.HTTPFail(ctx, http.StatusNotFound, errors.New("instance not found"), "instance not found")
+		}
+	}
+
+	var events []string
+	for _, event := range strings.Split(request.Events, ",") {
+		if event = strings.TrimSpace(event); event != "" {
+			events = append(events, event)
+		}
+	}
+
+	// The API key was already checked, so the handshake accepts any origin.
+	server := websocket.Server{
+		Handler: func(conn *websocket.Conn) {
+			s.stream(conn, request.InstanceID, events, request.Base64)
+		},
+	}
+	server.ServeHTTP(ctx.Response(), ctx.Request())
+	return nil
+}
+
+func (s *Events) stream(conn *websocket.Conn, instanceID string, events []string, base64 bool) {
+	defer conn.Close()
+
+	sub := s.subscribe(instanceID, events, base64)
+	defer sub.Close()
+
+	// the errors are written by the loop below, the only writer of the stream
+	notices := make(chan dto.StreamEventsNotice, 1)
+	go func() {
+		defer sub.Close()
+		for {
+			var frame []byte
+			if err := websocket.Message.Receive(conn, &frame); err != nil {
+				return
+			}
+
+			var msg dto.StreamEventsSubscribe
+			if err := json.Unmarshal(frame, &msg); err != nil {
+				select {
+				case notices <- dto.StreamEventsNotice{Error: "invalid subscription: " + err.Error()}:
+				default:
+				}
+				continue
+			}
+			sub.SetEvents(msg.Events)
+		}
+	}()
+
+	ping := time.NewTicker(streamPingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case data := <-sub.C():
+			_ = conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+			if err := websocket.Message.Send(conn, string(data)); err != nil {
+				zap.L().Debug("event stream closed", zap.String("instance", instanceID), zap.Error(err))
+				return
+			}
+		case notice := <-notices:
+			_ = conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+			if err := websocket.JSON.Send(conn, notice); err != nil {
+				zap.L().Debug("event stream closed", zap.String("instance", instanceID), zap.Error(err))
+				return
+			}
+		case <-ping.C:
+			_ = conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+			conn.PayloadType = websocket.PingFrame
+			_, err := conn.Write(nil)
+			conn.PayloadType = websocket.TextFrame
+			if err != nil {
+				zap.L().Debug("event stream closed", zap.String("instance", instanceID), zap.Error(err))
+				return
+			}
+		case <-sub.Done():
+			if sub.Overflowed() {
+				_ = conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+				_ = websocket.JSON.Send(conn, dto.StreamEventsNotice{
+					Error: "slow consumer: events were dropped, reconnect to resume",
+				})
+			}
+			return
+		}
+	}
+}
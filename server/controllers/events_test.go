@@ -0,0 +1,106 @@
+package controllers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/verbeux-ai/whatsmiau/server/dto"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"golang.org/x/net/websocket"
+)
+
+// testSubscription is a subscription that never receives events.
+type testSubscription struct {
+	ch     chan []byte
+	done   chan struct{}
+	once   sync.Once
+	events atomic.Pointer[[]string]
+}
+
+func newTestSubscription() *testSubscription {
+	return &testSubscription{ch: make(chan []byte), done: make(chan struct{})}
+}
+
+func (s *testSubscription) C() <-chan []byte          { return s.ch }
+func (s *testSubscription) Done() <-chan struct{}     { return s.done }
+func (s *testSubscription) Overflowed() bool          { return false }
+func (s *testSubscription) SetEvents(events []string) { s.events.Store(&events) }
+func (s *testSubscription) Close()                    { s.once.Do(func() { close(s.done) }) }
+
+// readStreamFrame returns the next data frame, counting the pings before it.
+func readStreamFrame(t *testing.T, conn *websocket.Conn, pings *int) []byte {
+	t.Helper()
+	for {
+		frame, err := conn.NewFrameReader()
+		if err != nil {
+			t.Fatalf("read frame: %v", err)
+		}
+		data, err := io.ReadAll(frame)
+		if err != nil {
+			t.Fatalf("read frame: %v", err)
+		}
+
+		switch frame.PayloadType() {
+		case websocket.PingFrame:
+			*pings++
+		case websocket.TextFrame:
+			return data
+		}
+	}
+}
+
+func TestStreamOverH2C(t *testing.T) {
+	interval := streamPingInterval
+	streamPingInterval = 20 * time.Millisecond
+	defer func() { streamPingInterval = interval }()
+
+	// served like main does with StartH2CServer
+	app := echo.New()
+	sub := newTestSubscription()
+	defer sub.Close()
+	events := &Events{subscribe: func(string, []string, bool) eventSubscription { return sub }}
+	app.GET("/events", events.Stream)
+	server := httptest.NewServer(h2c.NewHandler(app, &http2.Server{}))
+	defer server.Close()
+
+	conn, err := websocket.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/events", "", server.URL)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	pings := 0
+	for range 2 {
+		if err := websocket.Message.Send(conn, `{"events": `); err != nil {
+			t.Fatalf("send: %v", err)
+		}
+
+		var notice dto.StreamEventsNotice
+		if err := json.Unmarshal(readStreamFrame(t, conn, &pings), &notice); err != nil || notice.Error == "" {
+			t.Fatalf("expected an error notice, got %+v (%v)", notice, err)
+		}
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	for _, frame := range []string{`{"events": ["MESSAGES_UPSERT"]}`, `nope`} {
+		if err := websocket.Message.Send(conn, frame); err != nil {
+			t.Fatalf("send: %v", err)
+		}
+	}
+	readStreamFrame(t, conn, &pings)
+	if events := sub.events.Load(); events == nil || len(*events) != 1 || (*events)[0] != "MESSAGES_UPSERT" {
+		t.Errorf("expected the filter of the valid frame, got %v", events)
+	}
+	if pings == 0 {
+		t.Error("expected the idle stream to be pinged")
+	}
+}
@@ -83,6 +83,7 @@ func (s *Instance) Create(ctx echo.Context) error {
 		zap.L().Error("failed to create instance", zap.Error(err))
 		return utils.HTTPFail(ctx, http.StatusInternalServerError, err, "failed to create instance")
 	}
+	s.whatsmiau.InstanceCreated(request.Instance)
 
 	// If migration data is present, import the Baileys session
 	if request.Migration != nil {
@@ -93,7 +94,7 @@ func (s *Instance) Create(ctx echo.Context) error {
 		}
 
 		return ctx.JSON(http.StatusCreated, dto.CreateInstanceResponse{
-			Instance: request.Instance,
+			Instance: request.Instance.Redacted(),
 			Migration: &dto.MigrationResult{
 				JID:       result.JID,
 				LID:       result.LID,
@@ -104,7 +105,7 @@ func (s *Instance) Create(ctx echo.Context) error {
 	}
 
 	return ctx.JSON(http.StatusCreated, dto.CreateInstanceResponse{
-		Instance: request.Instance,
+		Instance: request.Instance.Redacted(),
 	})
 }
 
@@ -153,7 +154,7 @@ func (s *Instance) Update(ctx echo.Context) error {
 	}
 
 	return ctx.JSON(http.StatusCreated, dto.UpdateInstanceResponse{
-		Instance: instance,
+		Instance: instance.Redacted(),
 	})
 }
 
@@ -194,7 +195,7 @@ func (s *Instance) List(ctx echo.Context) error {
 		}
 
 		response = append(response, dto.ListInstancesResponse{
-			Instance:     &instance,
+			Instance:     instance.Redacted(),
 			OwnerJID:     jid.ToNonAD().String(),
 			InstanceName: instance.ID,
 		})
@@ -435,6 +436,7 @@ func (s *Instance) Delete(ctx echo.Context) error {
 		zap.L().Error("failed to delete instance", zap.Error(err))
 		return utils.HTTPFail(ctx, http.StatusInternalServerError, err, "failed to delete instance")
 	}
+	s.whatsmiau.InstanceDeleted(&result[0])
 
 	return ctx.JSON(http.StatusOK, dto.DeleteInstanceResponse{
 		Message: "instance deleted",
@@ -71,18 +71,20 @@ func ParseManagerTemplates() ManagerTemplates {
 }
 
 type Manager struct {
-	repo      interfaces.InstanceRepository
-	whatsmiau *whatsmiau.Whatsmiau
-	tmpl      ManagerTemplates
-	validate  *validator.Validate
+	repo       interfaces.InstanceRepository
+	deliveries interfaces.WebhookDeliveryRepository
+	whatsmiau  *whatsmiau.Whatsmiau
+	tmpl       ManagerTemplates
+	validate   *validator.Validate
 }
 
-func NewManager(repo interfaces.InstanceRepository, w *whatsmiau.Whatsmiau, tmpl ManagerTemplates) *Manager {
+func NewManager(repo interfaces.InstanceRepository, deliveries interfaces.WebhookDeliveryRepository, w *whatsmiau.Whatsmiau, tmpl ManagerTemplates) *Manager {
 	return &Manager{
-		repo:      repo,
-		whatsmiau: w,
-		tmpl:      tmpl,
-		validate:  validator.New(),
+		repo:       repo,
+		deliveries: deliveries,
+		whatsmiau:  w,
+		tmpl:       tmpl,
+		validate:   validator.New(),
 	}
 }
 
@@ -259,12 +261,18 @@ func (s *Manager) GetInstance(ctx echo.Context) error {
 		status = "error"
 	}
 
+	deliveryStats, err := s.deliveries.Stats(c, id)
+	if err != nil {
+		zap.L().Error("failed to get webhook delivery stats", zap.String("id", id), zap.Error(err))
+	}
+
 	setHTMLContentType(ctx)
 	data := map[string]interface{}{
 		"Instance":            &inst,
 		"Status":              string(status),
 		"ID":                  id,
 		"WebhookEventOptions": webhookEventOptions,
+		"DeliveryStats":       deliveryStats,
 	}
 	return s.tmpl.Instance.ExecuteTemplate(ctx.Response(), "instance.html", data)
 }
@@ -1,12 +1,14 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
 	"time"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v4"
 	"github.com/verbeux-ai/whatsmiau/lib/whatsmiau"
+	"github.com/verbeux-ai/whatsmiau/repositories/polls"
 	"github.com/verbeux-ai/whatsmiau/server/dto"
 	"github.com/verbeux-ai/whatsmiau/utils"
 	"go.mau.fi/whatsmeow/types"
@@ -419,3 +421,96 @@ func (s *Message) SendStatus(ctx echo.Context) error {
 		InstanceId:       request.InstanceID,
 	})
 }
+
+// UpdateMessage godoc
+// @Summary      Edit a sent message
+// @Description  Replaces the text, or the caption of an image, video or document, of a message sent by the instance. WhatsApp only accepts edits up to 15 minutes after sending.
+// @Tags         Message
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        instance  path      string                    true  "Instance ID"
+// @Param        body      body      dto.UpdateMessageRequest  true  "Message to edit and its new text"
+// @Success      200       {object}  dto.UpdateMessageResponse
+// @Failure      400       {object}  utils.HTTPErrorResponse
+// @Failure      422       {object}  utils.HTTPErrorResponse
+// @Failure      500       {object}  utils.HTTPErrorResponse
+// @Router       /instance/{instance}/message/update [post]
+// @Router       /message/updateMessage/{instance} [post]
+func (s *Message) UpdateMessage(ctx echo.Context) error {
+	var request dto.UpdateMessageRequest
+	if err := ctx.Bind(&request); err != nil {
+		return utils.HTTPFail(ctx, http.StatusUnprocessableEntity, err, "failed to bind request body")
+	}
+
+	if err := validator.New().Struct(&request); err != nil {
+		return utils.HTTPFail(ctx, http.StatusBadRequest, err, "invalid request body")
+	}
+
+	jid, err := numberToJid(request.Number)
+	if err != nil {
+		zap.L().Error("error converting number to jid", zap.Error(err))
+		return utils.HTTPFail(ctx, http.StatusBadRequest, err, "invalid number format")
+	}
+
+	res, err := s.whatsmiau.EditMessage(ctx.Request().Context(), &whatsmiau.EditMessageRequest{
+		InstanceID: request.InstanceID,
+		RemoteJID:  jid,
+		MessageID:  request.Key.Id,
+		Text:       request.Text,
+		Type:       whatsmiau.EditMessageType(request.Type),
+	})
+	if err != nil {
+		zap.L().Error("Whatsmiau.EditMessage failed", zap.Error(err))
+		return utils.HTTPFail(ctx, http.StatusInternalServerError, err, "failed to edit message")
+	}
+
+	return ctx.JSON(http.StatusOK, dto.UpdateMessageResponse{
+		Key: dto.MessageResponseKey{
+			RemoteJid: request.Number,
+			FromMe:    true,
+			Id:        request.Key.Id,
+		},
+		EditId:           res.ID,
+		Status:           "edited",
+		MessageType:      "editedMessage",
+		MessageTimestamp: int(res.CreatedAt.Unix()),
+		InstanceId:       request.InstanceID,
+	})
+}
+
+// PollResults godoc
+// @Summary      Get poll results
+// @Description  Returns the current votes of a poll sent or received by the instance, tallied per option. Polls are kept for POLL_RETENTION after the last vote.
+// @Tags         Message
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        instance  path      string  true  "Instance ID"
+// @Param        id        path      string  true  "Poll message ID"
+// @Success      200       {object}  dto.PollResultsResponse
+// @Failure      400       {object}  utils.HTTPErrorResponse
+// @Failure      404       {object}  utils.HTTPErrorResponse
+// @Failure      500       {object}  utils.HTTPErrorResponse
+// @Router       /instance/{instance}/message/poll/{id} [get]
+// @Router       /message/pollResults/{instance}/{id} [get]
+func (s *Message) PollResults(ctx echo.Context) error {
+	var request dto.PollResultsRequest
+	if err := ctx.Bind(&request); err != nil {
+		return utils.HTTPFail(ctx, http.StatusUnprocessableEntity, err, "failed to bind request")
+	}
+
+	if err := validator.New().Struct(&request); err != nil {
+		return utils.HTTPFail(ctx, http.StatusBadRequest, err, "invalid request")
+	}
+
+	results, err := s.whatsmiau.PollResults(ctx.Request().Context(), request.InstanceID, request.ID)
+	if err != nil {
+		if errors.Is(err, polls.ErrorNotFound) {
+			return utils.HTTPFail(ctx, http.StatusNotFound, err, "poll not found")
+		}
+		zap.L().Error("Whatsmiau.PollResults failed", zap.Error(err))
+		return utils.HTTPFail(ctx, http.StatusInternalServerError, err, "failed to get poll results")
+	}
+
+	return ctx.JSON(http.StatusOK, dto.PollResultsResponse{PollResults: results})
+}
@@ -0,0 +1,117 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"github.com/verbeux-ai/whatsmiau/interfaces"
+	"github.com/verbeux-ai/whatsmiau/lib/whatsmiau"
+	"github.com/verbeux-ai/whatsmiau/models"
+	"github.com/verbeux-ai/whatsmiau/repositories/instances"
+	"github.com/verbeux-ai/whatsmiau/server/dto"
+	"github.com/verbeux-ai/whatsmiau/utils"
+	"go.uber.org/zap"
+)
+
+type Settings struct {
+	repo      interfaces.InstanceRepository
+	whatsmiau *whatsmiau.Whatsmiau
+	validate  *validator.Validate
+}
+
+func NewSettings(repository interfaces.InstanceRepository, whatsmiau *whatsmiau.Whatsmiau) *Settings {
+	return &Settings{
+		repo:      repository,
+		whatsmiau: whatsmiau,
+		validate:  validator.New(),
+	}
+}
+
+// Set godoc
+// @Summary      Update instance settings
+// @Description  Changes the behavior settings present in the body and leaves the others as they are. The running client follows them right away; syncFullHistory and syncRecentHistory only apply to the next pairing.
+// @Tags         Settings
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        instance  path      string                   true  "Instance ID"
+// @Param        body      body      dto.SetSettingsRequest  true  "Settings to change"
+// @Success      200       {object}  dto.SettingsResponse
+// @Failure      400       {object}  utils.HTTPErrorResponse
+// @Failure      404       {object}  utils.HTTPErrorResponse
+// @Failure      422       {object}  utils.HTTPErrorResponse
+// @Failure      500       {object}  utils.HTTPErrorResponse
+// @Router       /settings/set/{instance} [post]
+func (s *Settings) Set(ctx echo.Context) error {
+	var request dto.SetSettingsRequest
+	if err := ctx.Bind(&request); err != nil {
+		return utils.HTTPFail(ctx, http.StatusUnprocessableEntity, err, "failed to bind request body")
+	}
+
+	if err := s.validate.Struct(&request); err != nil {
+		return utils.HTTPFail(ctx, http.StatusBadRequest, err, "invalid request body")
+	}
+
+	instance, err := s.repo.UpdateSettings(ctx.Request().Context(), request.InstanceID, &request.InstanceSettings)
+	if err != nil {
+		if errors.Is(err, instances.ErrorNotFound) {
+			return utils.HTTPFail(ctx, http.StatusNotFound, err, "instance not found")
+		}
+		zap.L().Error("failed to update settings", zap.Error(err))
+		return utils.HTTPFail(ctx, http.StatusInternalServerError, err, "failed to update settings")
+	}
+
+	s.whatsmiau.SettingsUpdated(instance)
+
+	return ctx.JSON(http.StatusOK, settingsResponse(instance))
+}
+
+// Find godoc
+// @Summary      Get instance settings
+// @Description  Returns the behavior settings of an instance
+// @Tags         Settings
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        instance  path      string  true  "Instance ID"
+// @Success      200       {object}  dto.SettingsResponse
+// @Failure      400       {object}  utils.HTTPErrorResponse
+// @Failure      404       {object}  utils.HTTPErrorResponse
+// @Failure      500       {object}  utils.HTTPErrorResponse
+// @Router       /settings/find/{instance} [get]
+func (s *Settings) Find(ctx echo.Context) error {
+	var request dto.FindSettingsRequest
+	if err := ctx.Bind(&request); err != nil {
+		return utils.HTTPFail(ctx, http.StatusUnprocessableEntity, err, "failed to bind request")
+	}
+
+	if err := s.validate.Struct(&request); err != nil {
+		return utils.HTTPFail(ctx, http.StatusBadRequest, err, "invalid request")
+	}
+
+	result, err := s.repo.List(ctx.Request().Context(), request.InstanceID)
+	if err != nil {
+		zap.L().Error("failed to list instances", zap.Error(err))
+		return utils.HTTPFail(ctx, http.StatusInternalServerError, err, "failed to get instance")
+	}
+
+	if len(result) == 0 {
+		return utils.HTTPFail(ctx, http.StatusNotFound, instances.ErrorNotFound, "instance not found")
+	}
+
+	return ctx.JSON(http.StatusOK, settingsResponse(&result[0]))
+}
+
+func settingsResponse(instance *models.Instance) dto.SettingsResponse {
+	return dto.SettingsResponse{
+		RejectCall:        instance.RejectCall,
+		MsgCall:           instance.MsgCall,
+		GroupsIgnore:      instance.GroupsIgnore,
+		AlwaysOnline:      instance.AlwaysOnline,
+		ReadMessages:      instance.ReadMessages,
+		ReadStatus:        instance.ReadStatus,
+		SyncFullHistory:   instance.SyncFullHistory,
+		SyncRecentHistory: instance.SyncRecentHistory,
+	}
+}
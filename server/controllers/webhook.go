@@ -1,14 +1,18 @@
 package controllers
 
 import (
+	"encoding/json"
 	"errors"
 	"net/http"
+	"time"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v4"
+	"github.com/verbeux-ai/whatsmiau/env"
 	"github.com/verbeux-ai/whatsmiau/interfaces"
 	"github.com/verbeux-ai/whatsmiau/lib/whatsmiau"
 	"github.com/verbeux-ai/whatsmiau/models"
+	"github.com/verbeux-ai/whatsmiau/repositories/deadletters"
 	"github.com/verbeux-ai/whatsmiau/repositories/instances"
 	"github.com/verbeux-ai/whatsmiau/server/dto"
 	"github.com/verbeux-ai/whatsmiau/utils"
@@ -16,16 +20,20 @@ import (
 )
 
 type Webhook struct {
-	repo      interfaces.InstanceRepository
-	whatsmiau *whatsmiau.Whatsmiau
-	validate  *validator.Validate
+	repo        interfaces.InstanceRepository
+	deadLetters interfaces.DeadLetterRepository
+	deliveries  interfaces.WebhookDeliveryRepository
+	whatsmiau   *whatsmiau.Whatsmiau
+	validate    *validator.Validate
 }
 
-func NewWebhooks(repository interfaces.InstanceRepository, whatsmiau *whatsmiau.Whatsmiau) *Webhook {
+func NewWebhooks(repository interfaces.InstanceRepository, deadLetters interfaces.DeadLetterRepository, deliveries interfaces.WebhookDeliveryRepository, whatsmiau *whatsmiau.Whatsmiau) *Webhook {
 	return &Webhook{
-		repo:      repository,
-		whatsmiau: whatsmiau,
-		validate:  validator.New(),
+		repo:        repository,
+		deadLetters: deadLetters,
+		deliveries:  deliveries,
+		whatsmiau:   whatsmiau,
+		validate:    validator.New(),
 	}
 }
 
@@ -39,28 +47,62 @@ func (s *Webhook) Set(ctx echo.Context) error {
 		return utils.HTTPFail(ctx, http.StatusBadRequest, err, "invalid request body")
 	}
 
+	gracePeriod := env.Env.WebhookSecretGracePeriod
+	if request.Webhook.SecretGracePeriod > 0 {
+		gracePeriod = time.Duration(request.Webhook.SecretGracePeriod) * time.Second
+	}
+	previousSecretExpiresAt := time.Now().Add(gracePeriod)
+
+	var preview json.RawMessage
+	if request.Webhook.Template != nil && *request.Webhook.Template != "" {
+		var err error
+		preview, err = whatsmiau.PreviewWebhookTemplate(&models.Instance{ID: request.InstanceID}, *request.Webhook.Template, whatsmiau.WookMessagesUpsert)
+		if err != nil {
+			return utils.HTTPFail(ctx, http.StatusBadRequest, err, "invalid webhook template")
+		}
+	}
+
+	if err := whatsmiau.CheckRetryPolicy(request.Webhook.Retry); err != nil {
+		return utils.HTTPFail(ctx, http.StatusBadRequest, err, "invalid webhook retry policy")
+	}
+
 	c := ctx.Request().Context()
 	instance, err := s.repo.Update(c, request.InstanceID, &models.Instance{
 		ID: request.InstanceID,
 		Webhook: models.InstanceWebhook{
-			Enabled:  request.Webhook.Enabled,
-			Url:      request.Webhook.URL,
-			ByEvents: request.Webhook.ByEvents,
-			Base64:   request.Webhook.Base64,
-			Headers:  request.Webhook.Headers,
-			Events:   request.Webhook.Events,
+			Enabled:                 request.Webhook.Enabled,
+			Url:                     request.Webhook.URL,
+			ByEvents:                request.Webhook.ByEvents,
+			Base64:                  request.Webhook.Base64,
+			Headers:                 request.Webhook.Headers,
+			Events:                  request.Webhook.Events,
+			EventUrls:               request.Webhook.EventUrls,
+			Secret:                  request.Webhook.Secret,
+			PreviousSecretExpiresAt: &previousSecretExpiresAt,
+			RemoveSecret:            request.Webhook.RemoveSecret,
+			Auth:                    request.Webhook.Auth,
+			RateLimit:               request.Webhook.RateLimit,
+			Ordered:                 request.Webhook.Ordered,
+			Retry:                   request.Webhook.Retry,
+			Batch:                   request.Webhook.Batch,
+			Gzip:                    request.Webhook.Gzip,
+			Template:                request.Webhook.Template,
 		},
 	})
 	if err != nil {
 		if errors.Is(err, instances.ErrorNotFound) {
 			return utils.HTTPFail(ctx, http.StatusNotFound, err, "instance not found")
 		}
+		if errors.Is(err, models.ErrBatchedTemplate) {
+			return utils.HTTPFail(ctx, http.StatusBadRequest, err, "invalid webhook")
+		}
 		zap.L().Error("failed to update webhook", zap.Error(err))
 		return utils.HTTPFail(ctx, http.StatusInternalServerError, err, "failed to update webhook")
 	}
 
 	return ctx.JSON(http.StatusOK, dto.SetWebhookResponse{
-		Webhook: &instance.Webhook,
+		Webhook:         webhookConfig(instance.Webhook),
+		TemplatePreview: preview,
 	})
 }
 
@@ -86,6 +128,173 @@ func (s *Webhook) Find(ctx echo.Context) error {
 	}
 
 	return ctx.JSON(http.StatusOK, dto.FindWebhookResponse{
-		Webhook: &result[0].Webhook,
+		Webhook: webhookConfig(result[0].Webhook),
+	})
+}
+
+func webhookConfig(webhook models.InstanceWebhook) *dto.WebhookConfig {
+	return &dto.WebhookConfig{
+		InstanceWebhook: webhook.Redacted(),
+		HasSecret:       webhook.Secret != "",
+	}
+}
+
+// ListDeadLetters godoc
+// @Summary      List failed webhook deliveries
+// @Description  Returns the webhook deliveries of an instance that failed after every retry, oldest first
+// @Tags         Webhook
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        instance  path      string  true   "Instance ID"
+// @Param        from      query     string  false  "Only deliveries that failed at or after this RFC3339 time"
+// @Param        to        query     string  false  "Only deliveries that failed at or before this RFC3339 time"
+// @Param        limit     query     int     false  "Maximum number of results"
+// @Success      200       {object}  dto.ListDeadLettersResponse
+// @Failure      400       {object}  utils.HTTPErrorResponse
+// @Failure      422       {object}  utils.HTTPErrorResponse
+// @Failure      500       {object}  utils.HTTPErrorResponse
+// @Router       /webhook/dead-letters/{instance} [get]
+func (s *Webhook) ListDeadLetters(ctx echo.Context) error {
+	var request dto.ListDeadLettersRequest
+	if err := ctx.Bind(&request); err != nil {
+		return utils.HTTPFail(ctx, http.StatusUnprocessableEntity, err, "failed to bind request body")
+	}
+
+	if err := s.validate.Struct(&request); err != nil {
+		return utils.HTTPFail(ctx, http.StatusBadRequest, err, "invalid request body")
+	}
+
+	c := ctx.Request().Context()
+	letters, err := s.deadLetters.List(c, request.InstanceID, request.From, request.To, request.Limit)
+	if err != nil {
+		zap.L().Error("failed to list dead letters", zap.Error(err))
+		return utils.HTTPFail(ctx, http.StatusInternalServerError, err, "failed to list dead letters")
+	}
+
+	return ctx.JSON(http.StatusOK, dto.ListDeadLettersResponse{
+		DeadLetters: letters,
+	})
+}
+
+// ReplayDeadLetter godoc
+// @Summary      Replay one failed webhook delivery
+// @Description  Puts a failed delivery back on the webhook queue and removes it from the dead letter store
+// @Tags         Webhook
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        instance  path      string  true  "Instance ID"
+// @Param        id        path      string  true  "Dead letter ID"
+// @Success      200       {object}  dto.ReplayDeadLettersResponse
+// @Failure      400       {object}  utils.HTTPErrorResponse
+// @Failure      404       {object}  utils.HTTPErrorResponse
+// @Failure      422       {object}  utils.HTTPErrorResponse
+// @Failure      500       {object}  utils.HTTPErrorResponse
+// @Router       /webhook/dead-letters/{instance}/replay/{id} [post]
+func (s *Webhook) ReplayDeadLetter(ctx echo.Context) error {
+	var request dto.ReplayDeadLetterRequest
+	if err := ctx.Bind(&request); err != nil {
+		return utils.HTTPFail(ctx, http.StatusUnprocessableEntity, err, "failed to bind request body")
+	}
+
+	if err := s.validate.Struct(&request); err != nil {
+		return utils.HTTPFail(ctx, http.StatusBadRequest, err, "invalid request body")
+	}
+
+	c := ctx.Request().Context()
+	letter, err := s.deadLetters.Get(c, request.InstanceID, request.ID)
+	if err != nil {
+		if errors.Is(err, deadletters.ErrorNotFound) {
+			return utils.HTTPFail(ctx, http.StatusNotFound, err, "dead letter not found")
+		}
+		zap.L().Error("failed to get dead letter", zap.Error(err))
+		return utils.HTTPFail(ctx, http.StatusInternalServerError, err, "failed to get dead letter")
+	}
+
+	replayed, err := s.whatsmiau.ReplayDeadLetters(c, []models.DeadLetter{*letter})
+	if err != nil {
+		zap.L().Error("failed to replay dead letter", zap.Error(err))
+		return utils.HTTPFail(ctx, http.StatusInternalServerError, err, "failed to replay dead letter")
+	}
+
+	return ctx.JSON(http.StatusOK, dto.ReplayDeadLettersResponse{
+		Replayed: replayed,
+	})
+}
+
+// ReplayDeadLetters godoc
+// @Summary      Replay failed webhook deliveries in a time range
+// @Description  Puts every failed delivery of the instance within the range back on the webhook queue. An empty range replays all of them.
+// @Tags         Webhook
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        instance  path      string                        true  "Instance ID"
+// @Param        body      body      dto.ReplayDeadLettersRequest  true  "Time range"
+// @Success      200       {object}  dto.ReplayDeadLettersResponse
+// @Failure      400       {object}  utils.HTTPErrorResponse
+// @Failure      422       {object}  utils.HTTPErrorResponse
+// @Failure      500       {object}  utils.HTTPErrorResponse
+// @Router       /webhook/dead-letters/{instance}/replay [post]
+func (s *Webhook) ReplayDeadLetters(ctx echo.Context) error {
+	var request dto.ReplayDeadLettersRequest
+	if err := ctx.Bind(&request); err != nil {
+		return utils.HTTPFail(ctx, http.StatusUnprocessableEntity, err, "failed to bind request body")
+	}
+
+	if err := s.validate.Struct(&request); err != nil {
+		return utils.HTTPFail(ctx, http.StatusBadRequest, err, "invalid request body")
+	}
+
+	c := ctx.Request().Context()
+	letters, err := s.deadLetters.List(c, request.InstanceID, request.From, request.To, 0)
+	if err != nil {
+		zap.L().Error("failed to list dead letters", zap.Error(err))
+		return utils.HTTPFail(ctx, http.StatusInternalServerError, err, "failed to list dead letters")
+	}
+
+	replayed, err := s.whatsmiau.ReplayDeadLetters(c, letters)
+	if err != nil {
+		zap.L().Error("failed to replay dead letters", zap.Int("replayed", replayed), zap.Error(err))
+		return utils.HTTPFail(ctx, http.StatusInternalServerError, err, "failed to replay dead letters")
+	}
+
+	return ctx.JSON(http.StatusOK, dto.ReplayDeadLettersResponse{
+		Replayed: replayed,
+	})
+}
+
+// PurgeDeadLetters godoc
+// @Summary      Purge failed webhook deliveries
+// @Description  Deletes the failed deliveries of the instance within the range. An empty range deletes all of them.
+// @Tags         Webhook
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        instance  path      string  true   "Instance ID"
+// @Param        from      query     string  false  "Only deliveries that failed at or after this RFC3339 time"
+// @Param        to        query     string  false  "Only deliveries that failed at or before this RFC3339 time"
+// @Success      200       {object}  dto.PurgeDeadLettersResponse
+// @Failure      400       {object}  utils.HTTPErrorResponse
+// @Failure      422       {object}  utils.HTTPErrorResponse
+// @Failure      500       {object}  utils.HTTPErrorResponse
+// @Router       /webhook/dead-letters/{instance} [delete]
+func (s *Webhook) PurgeDeadLetters(ctx echo.Context) error {
+	var request dto.PurgeDeadLettersRequest
+	if err := ctx.Bind(&request); err != nil {
+		return utils.HTTPFail(ctx, http.StatusUnprocessableEntity, err, "failed to bind request body")
+	}
+
+	if err := s.validate.Struct(&request); err != nil {
+		return utils.HTTPFail(ctx, http.StatusBadRequest, err, "invalid request body")
+	}
+
+	c := ctx.Request().Context()
+	purged, err := s.deadLetters.Purge(c, request.InstanceID, request.From, request.To)
+	if err != nil {
+		zap.L().Error("failed to purge dead letters", zap.Error(err))
+		return utils.HTTPFail(ctx, http.StatusInternalServerError, err, "failed to purge dead letters")
+	}
+
+	return ctx.JSON(http.StatusOK, dto.PurgeDeadLettersResponse{
+		Purged: purged,
 	})
 }
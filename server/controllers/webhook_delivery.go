@@ -0,0 +1,242 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/verbeux-ai/whatsmiau/lib/whatsmiau"
+	"github.com/verbeux-ai/whatsmiau/models"
+	"github.com/verbeux-ai/whatsmiau/repositories/instances"
+	"github.com/verbeux-ai/whatsmiau/server/dto"
+	"github.com/verbeux-ai/whatsmiau/utils"
+	"go.uber.org/zap"
+)
+
+// ListDeliveries godoc
+// @Summary      List webhook delivery attempts
+// @Description  Returns the latest webhook delivery attempts of an instance, newest first. Only the last WEBHOOK_DELIVERY_LOG_SIZE attempts are kept.
+// @Tags         Webhook
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        instance      path      string  true   "Instance ID"
+// @Param        event         query     string  false  "Event, e.g. MESSAGES_UPSERT"
+// @Param        subscription  query     string  false  "Subscription ID, or global for the global webhook"
+// @Param        messageId     query     string  false  "Message ID"
+// @Param        status        query     string  false  "success or failure"
+// @Param        statusCode    query     int     false  "Response status code"
+// @Param        from          query     string  false  "Only attempts at or after this RFC3339 time"
+// @Param        to            query     string  false  "Only attempts at or before this RFC3339 time"
+// @Param        limit         query     int     false  "Maximum number of results"
+// @Success      200           {object}  dto.ListWebhookDeliveriesResponse
+// @Failure      400           {object}  utils.HTTPErrorResponse
+// @Failure      422           {object}  utils.HTTPErrorResponse
+// @Failure      500           {object}  utils.HTTPErrorResponse
+// @Router       /webhook/deliveries/{instance} [get]
+func (s *Webhook) ListDeliveries(ctx echo.Context) error {
+	var request dto.ListWebhookDeliveriesRequest
+	if err := ctx.Bind(&request); err != nil {
+		return utils.HTTPFail(ctx, http.StatusUnprocessableEntity, err, "failed to bind request body")
+	}
+
+	if err := s.validate.Struct(&request); err != nil {
+		return utils.HTTPFail(ctx, http.StatusBadRequest, err, "invalid request body")
+	}
+
+	filter := models.WebhookDeliveryFilter{
+		Event:        request.Event,
+		Subscription: request.Subscription,
+		MessageID:    request.MessageID,
+		StatusCode:   request.StatusCode,
+		From:         request.From,
+		To:           request.To,
+		Limit:        request.Limit,
+	}
+	if request.Status != "" {
+		success := request.Status == "success"
+		filter.Success = &success
+	}
+
+	c := ctx.Request().Context()
+	deliveries, err := s.deliveries.List(c, request.InstanceID, filter)
+	if err != nil {
+		zap.L().Error("failed to list webhook deliveries", zap.Error(err))
+		return utils.HTTPFail(ctx, http.StatusInternalServerError, err, "failed to list webhook deliveries")
+	}
+
+	return ctx.JSON(http.StatusOK, dto.ListWebhookDeliveriesResponse{
+		Deliveries: deliveries,
+	})
+}
+
+// DeliveryStats godoc
+// @Summary      Webhook delivery statistics
+// @Description  Returns the success, failure and latency counters of the webhook deliveries of an instance
+// @Tags         Webhook
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        instance  path      string  true  "Instance ID"
+// @Success      200       {object}  dto.WebhookDeliveryStatsResponse
+// @Failure      400       {object}  utils.HTTPErrorResponse
+// @Failure      422       {object}  utils.HTTPErrorResponse
+// @Failure      500       {object}  utils.HTTPErrorResponse
+// @Router       /webhook/deliveries/{instance}/stats [get]
+func (s *Webhook) DeliveryStats(ctx echo.Context) error {
+	var request dto.WebhookDeliveryStatsRequest
+	if err := ctx.Bind(&request); err != nil {
+		return utils.HTTPFail(ctx, http.StatusUnprocessableEntity, err, "failed to bind request body")
+	}
+
+	if err := s.validate.Struct(&request); err != nil {
+		return utils.HTTPFail(ctx, http.StatusBadRequest, err, "invalid request body")
+	}
+
+	stats, err := s.deliveries.Stats(ctx.Request().Context(), request.InstanceID)
+	if err != nil {
+		zap.L().Error("failed to get webhook delivery stats", zap.Error(err))
+		return utils.HTTPFail(ctx, http.StatusInternalServerError, err, "failed to get webhook delivery stats")
+	}
+
+	return ctx.JSON(http.StatusOK, dto.WebhookDeliveryStatsResponse{
+		Stats:       stats,
+		SuccessRate: stats.SuccessRate(),
+	})
+}
+
+// ResetDeliveries godoc
+// @Summary      Reset the webhook delivery log
+// @Description  Clears the delivery log and the statistics of an instance
+// @Tags         Webhook
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        instance  path  string  true  "Instance ID"
+// @Success      204
+// @Failure      400       {object}  utils.HTTPErrorResponse
+// @Failure      422       {object}  utils.HTTPErrorResponse
+// @Failure      500       {object}  utils.HTTPErrorResponse
+// @Router       /webhook/deliveries/{instance} [delete]
+func (s *Webhook) ResetDeliveries(ctx echo.Context) error {
+	var request dto.WebhookDeliveryStatsRequest
+	if err := ctx.Bind(&request); err != nil {
+		return utils.HTTPFail(ctx, http.StatusUnprocessableEntity, err, "failed to bind request body")
+	}
+
+	if err := s.validate.Struct(&request); err != nil {
+		return utils.HTTPFail(ctx, http.StatusBadRequest, err, "invalid request body")
+	}
+
+	if err := s.deliveries.Reset(ctx.Request().Context(), request.InstanceID); err != nil {
+		zap.L().Error("failed to reset webhook deliveries", zap.Error(err))
+		return utils.HTTPFail(ctx, http.StatusInternalServerError, err, "failed to reset webhook deliveries")
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// Test godoc
+// @Summary      Test fire a webhook
+// @Description  Sends a synthetic event to the webhook of an instance, signed and authenticated like real deliveries, and returns the receiver's answer. The attempt is not retried nor recorded.
+// @Tags         Webhook
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        instance  path      string                  true  "Instance ID"
+// @Param        body      body      dto.TestWebhookRequest  true  "Event to fire"
+// @Success      200       {object}  dto.TestWebhookResponse
+// @Failure      400       {object}  utils.HTTPErrorResponse
+// @Failure      404       {object}  utils.HTTPErrorResponse
+// @Failure      422       {object}  utils.HTTPErrorResponse
+// @Failure      500       {object}  utils.HTTPErrorResponse
+// @Router       /webhook/test/{instance} [post]
+func (s *Webhook) Test(ctx echo.Context) error {
+	var request dto.TestWebhookRequest
+	if err := ctx.Bind(&request); err != nil {
+		return utils.HTTPFail(ctx, http.StatusUnprocessableEntity, err, "failed to bind request body")
+	}
+
+	if err := s.validate.Struct(&request); err != nil {
+		return utils.HTTPFail(ctx, http.StatusBadRequest, err, "invalid request body")
+	}
+
+	event, ok := whatsmiau.ParseWook(request.Event)
+	if !ok {
+		return utils.HTTPFail(ctx, http.StatusBadRequest, whatsmiau.ErrUnsupportedTestEvent, "unknown event")
+	}
+
+	instance, err := s.loadInstance(ctx.Request().Context(), request.InstanceID)
+	if err != nil {
+		if errors.Is(err, instances.ErrorNotFound) {
+			return utils.HTTPFail(ctx, http.StatusNotFound, err, "instance not found")
+		}
+		zap.L().Error("failed to get instance", zap.Error(err))
+		return utils.HTTPFail(ctx, http.StatusInternalServerError, err, "failed to get instance")
+	}
+
+	result, err := s.whatsmiau.TestWebhook(instance, request.Subscription, event)
+	if err != nil {
+		switch {
+		case errors.Is(err, whatsmiau.ErrTestSubscriptionAbsent):
+			return utils.HTTPFail(ctx, http.StatusNotFound, err, "subscription not found")
+		case errors.Is(err, whatsmiau.ErrUnsupportedTestEvent), errors.Is(err, whatsmiau.ErrTestWebhookURLEmpty):
+			return utils.HTTPFail(ctx, http.StatusBadRequest, err, err.Error())
+		}
+		zap.L().Error("failed to test webhook", zap.Error(err))
+		return utils.HTTPFail(ctx, http.StatusInternalServerError, err, "failed to test webhook")
+	}
+
+	return ctx.JSON(http.StatusOK, dto.TestWebhookResponse{
+		Result: result,
+	})
+}
+
+// PreviewTemplate godoc
+// @Summary      Preview a webhook template
+// @Description  Renders a payload template against a synthetic event of the instance without saving it, the same check done when a webhook or subscription is saved.
+// @Tags         Webhook
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        instance  path      string                             true  "Instance ID"
+// @Param        body      body      dto.PreviewWebhookTemplateRequest  true  "Template and sample event"
+// @Success      200       {object}  dto.PreviewWebhookTemplateResponse
+// @Failure      400       {object}  utils.HTTPErrorResponse
+// @Failure      404       {object}  utils.HTTPErrorResponse
+// @Failure      422       {object}  utils.HTTPErrorResponse
+// @Failure      500       {object}  utils.HTTPErrorResponse
+// @Router       /webhook/template/preview/{instance} [post]
+func (s *Webhook) PreviewTemplate(ctx echo.Context) error {
+	var request dto.PreviewWebhookTemplateRequest
+	if err := ctx.Bind(&request); err != nil {
+		return utils.HTTPFail(ctx, http.StatusUnprocessableEntity, err, "failed to bind request body")
+	}
+
+	if err := s.validate.Struct(&request); err != nil {
+		return utils.HTTPFail(ctx, http.StatusBadRequest, err, "invalid request body")
+	}
+
+	event := whatsmiau.WookMessagesUpsert
+	if request.Event != "" {
+		var ok bool
+		if event, ok = whatsmiau.ParseWook(request.Event); !ok {
+			return utils.HTTPFail(ctx, http.StatusBadRequest, whatsmiau.ErrUnsupportedTestEvent, "unknown event")
+		}
+	}
+
+	instance, err := s.loadInstance(ctx.Request().Context(), request.InstanceID)
+	if err != nil {
+		if errors.Is(err, instances.ErrorNotFound) {
+			return utils.HTTPFail(ctx, http.StatusNotFound, err, "instance not found")
+		}
+		zap.L().Error("failed to get instance", zap.Error(err))
+		return utils.HTTPFail(ctx, http.StatusInternalServerError, err, "failed to get instance")
+	}
+
+	preview, err := whatsmiau.PreviewWebhookTemplate(instance, request.Template, event)
+	if err != nil {
+		return utils.HTTPFail(ctx, http.StatusBadRequest, err, "invalid webhook template")
+	}
+
+	return ctx.JSON(http.StatusOK, dto.PreviewWebhookTemplateResponse{
+		Preview: preview,
+	})
+}
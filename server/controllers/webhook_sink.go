@@ -0,0 +1,93 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/verbeux-ai/whatsmiau/models"
+	"github.com/verbeux-ai/whatsmiau/repositories/instances"
+	"github.com/verbeux-ai/whatsmiau/server/dto"
+	"github.com/verbeux-ai/whatsmiau/utils"
+	"go.uber.org/zap"
+)
+
+// SetSink godoc
+// @Summary      Set the event sink
+// @Description  Publishes the events of the instance to a NATS subject or AMQP topic exchange, next to its webhooks. Send {"sink": {"type": ""}} to remove it.
+// @Tags         Webhook
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        instance  path      string              true  "Instance ID"
+// @Param        body      body      dto.SetSinkRequest  true  "Sink"
+// @Success      200       {object}  dto.SinkResponse
+// @Failure      400       {object}  utils.HTTPErrorResponse
+// @Failure      404       {object}  utils.HTTPErrorResponse
+// @Failure      422       {object}  utils.HTTPErrorResponse
+// @Failure      500       {object}  utils.HTTPErrorResponse
+// @Router       /webhook/sink/{instance} [post]
+func (s *Webhook) SetSink(ctx echo.Context) error {
+	var request dto.SetSinkRequest
+	if err := ctx.Bind(&request); err != nil {
+		return utils.HTTPFail(ctx, http.StatusUnprocessableEntity, err, "failed to bind request body")
+	}
+
+	if err := s.validate.Struct(&request); err != nil {
+		return utils.HTTPFail(ctx, http.StatusBadRequest, err, "invalid request body")
+	}
+
+	c := ctx.Request().Context()
+	instance, err := s.repo.Update(c, request.InstanceID, &models.Instance{
+		ID:   request.InstanceID,
+		Sink: &request.Sink,
+	})
+	if err != nil {
+		if errors.Is(err, instances.ErrorNotFound) {
+			return utils.HTTPFail(ctx, http.StatusNotFound, err, "instance not found")
+		}
+		zap.L().Error("failed to update sink", zap.Error(err))
+		return utils.HTTPFail(ctx, http.StatusInternalServerError, err, "failed to update sink")
+	}
+	s.whatsmiau.SinkUpdated(request.InstanceID)
+
+	return ctx.JSON(http.StatusOK, dto.SinkResponse{
+		Sink: instance.Sink.Redacted(),
+	})
+}
+
+// FindSink godoc
+// @Summary      Get the event sink
+// @Tags         Webhook
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        instance  path      string  true  "Instance ID"
+// @Success      200       {object}  dto.SinkResponse
+// @Failure      400       {object}  utils.HTTPErrorResponse
+// @Failure      404       {object}  utils.HTTPErrorResponse
+// @Failure      422       {object}  utils.HTTPErrorResponse
+// @Failure      500       {object}  utils.HTTPErrorResponse
+// @Router       /webhook/sink/{instance} [get]
+func (s *Webhook) FindSink(ctx echo.Context) error {
+	var request dto.FindSinkRequest
+	if err := ctx.Bind(&request); err != nil {
+		return utils.HTTPFail(ctx, http.StatusUnprocessableEntity, err, "failed to bind request body")
+	}
+
+	if err := s.validate.Struct(&request); err != nil {
+		return utils.HTTPFail(ctx, http.StatusBadRequest, err, "invalid request body")
+	}
+
+	instance, err := s.loadInstance(ctx.Request().Context(), request.InstanceID)
+	if err != nil {
+		if errors.Is(err, instances.ErrorNotFound) {
+			return utils.HTTPFail(ctx, http.StatusNotFound, err, "instance not found")
+		}
+		zap.L().Error("failed to list instances", zap.Error(err))
+		return utils.HTTPFail(ctx, http.StatusInternalServerError, err, "failed to get instance")
+	}
+
+	return ctx.JSON(http.StatusOK, dto.SinkResponse{
+		Sink: instance.Sink.Redacted(),
+	})
+}
@@ -0,0 +1,361 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/verbeux-ai/whatsmiau/env"
+	"github.com/verbeux-ai/whatsmiau/lib/whatsmiau"
+	"github.com/verbeux-ai/whatsmiau/models"
+	"github.com/verbeux-ai/whatsmiau/repositories/instances"
+	"github.com/verbeux-ai/whatsmiau/server/dto"
+	"github.com/verbeux-ai/whatsmiau/utils"
+	"go.uber.org/zap"
+	"golang.org/x/net/context"
+)
+
+var errSubscriptionNotFound = errors.New("subscription not found")
+
+// ListSubscriptions godoc
+// @Summary      List webhook subscriptions
+// @Description  Returns the additional webhook targets of an instance
+// @Tags         Webhook
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        instance  path      string  true  "Instance ID"
+// @Success      200       {object}  dto.ListWebhookSubscriptionsResponse
+// @Failure      400       {object}  utils.HTTPErrorResponse
+// @Failure      404       {object}  utils.HTTPErrorResponse
+// @Failure      422       {object}  utils.HTTPErrorResponse
+// @Failure      500       {object}  utils.HTTPErrorResponse
+// @Router       /webhook/subscriptions/{instance} [get]
+func (s *Webhook) ListSubscriptions(ctx echo.Context) error {
+	var request dto.ListWebhookSubscriptionsRequest
+	if err := ctx.Bind(&request); err != nil {
+		return utils.HTTPFail(ctx, http.StatusUnprocessableEntity, err, "failed to bind request body")
+	}
+
+	if err := s.validate.Struct(&request); err != nil {
+		return utils.HTTPFail(ctx, http.StatusBadRequest, err, "invalid request body")
+	}
+
+	instance, err := s.loadInstance(ctx.Request().Context(), request.InstanceID)
+	if err != nil {
+		return s.failInstance(ctx, err)
+	}
+
+	subscriptions := make([]dto.WebhookSubscriptionConfig, 0, len(instance.Subscriptions))
+	for _, subscription := range instance.Subscriptions {
+		subscriptions = append(subscriptions, *subscriptionConfig(subscription))
+	}
+
+	return ctx.JSON(http.StatusOK, dto.ListWebhookSubscriptionsResponse{
+		Subscriptions: subscriptions,
+	})
+}
+
+// GetSubscription godoc
+// @Summary      Get a webhook subscription
+// @Tags         Webhook
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        instance  path      string  true  "Instance ID"
+// @Param        id        path      string  true  "Subscription ID"
+// @Success      200       {object}  dto.WebhookSubscriptionResponse
+// @Failure      400       {object}  utils.HTTPErrorResponse
+// @Failure      404       {object}  utils.HTTPErrorResponse
+// @Failure      422       {object}  utils.HTTPErrorResponse
+// @Failure      500       {object}  utils.HTTPErrorResponse
+// @Router       /webhook/subscriptions/{instance}/{id} [get]
+func (s *Webhook) GetSubscription(ctx echo.Context) error {
+	var request dto.WebhookSubscriptionRequest
+	if err := ctx.Bind(&request); err != nil {
+		return utils.HTTPFail(ctx, http.StatusUnprocessableEntity, err, "failed to bind request body")
+	}
+
+	if err := s.validate.Struct(&request); err != nil {
+		return utils.HTTPFail(ctx, http.StatusBadRequest, err, "invalid request body")
+	}
+
+	instance, err := s.loadInstance(ctx.Request().Context(), request.InstanceID)
+	if err != nil {
+		return s.failInstance(ctx, err)
+	}
+
+	for _, subscription := range instance.Subscriptions {
+		if subscription.ID == request.ID {
+			return ctx.JSON(http.StatusOK, dto.WebhookSubscriptionResponse{
+				Subscription: subscriptionConfig(subscription),
+			})
+		}
+	}
+
+	return utils.HTTPFail(ctx, http.StatusNotFound, errSubscriptionNotFound, "subscription not found")
+}
+
+// CreateSubscription godoc
+// @Summary      Create a webhook subscription
+// @Description  Adds a webhook target with its own URL, events and chat filters to the instance
+// @Tags         Webhook
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        instance  path      string                                 true  "Instance ID"
+// @Param        body      body      dto.CreateWebhookSubscriptionRequest  true  "Subscription"
+// @Success      201       {object}  dto.WebhookSubscriptionResponse
+// @Failure      400       {object}  utils.HTTPErrorResponse
+// @Failure      404       {object}  utils.HTTPErrorResponse
+// @Failure      409       {object}  utils.HTTPErrorResponse
+// @Failure      422       {object}  utils.HTTPErrorResponse
+// @Failure      500       {object}  utils.HTTPErrorResponse
+// @Router       /webhook/subscriptions/{instance} [post]
+func (s *Webhook) CreateSubscription(ctx echo.Context) error {
+	var request dto.CreateWebhookSubscriptionRequest
+	if err := ctx.Bind(&request); err != nil {
+		return utils.HTTPFail(ctx, http.StatusUnprocessableEntity, err, "failed to bind request body")
+	}
+
+	if err := s.validate.Struct(&request); err != nil {
+		return utils.HTTPFail(ctx, http.StatusBadRequest, err, "invalid request body")
+	}
+
+	c := ctx.Request().Context()
+	instance, err := s.loadInstance(c, request.InstanceID)
+	if err != nil {
+		return s.failInstance(ctx, err)
+	}
+
+	preview, err := previewSubscriptionTemplate(instance, request.WebhookSubscriptionData)
+	if err != nil {
+		return utils.HTTPFail(ctx, http.StatusBadRequest, err, "invalid webhook template")
+	}
+
+	if err := whatsmiau.CheckRetryPolicy(request.Retry); err != nil {
+		return utils.HTTPFail(ctx, http.StatusBadRequest, err, "invalid webhook retry policy")
+	}
+
+	subscription := subscriptionFromRequest(request.WebhookSubscriptionData)
+	if err := subscription.Validate(); err != nil {
+		return utils.HTTPFail(ctx, http.StatusBadRequest, err, "invalid webhook subscription")
+	}
+	subscription.ID = uuid.NewString()
+	subscription.Secret = request.Secret
+
+	if _, err := s.repo.UpdateSubscriptions(c, request.InstanceID, func(instance *models.Instance) ([]models.WebhookSubscription, error) {
+		return append(instance.Subscriptions, subscription), nil
+	}); err != nil {
+		return s.failInstance(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusCreated, dto.WebhookSubscriptionResponse{
+		Subscription:    subscriptionConfig(subscription),
+		TemplatePreview: preview,
+	})
+}
+
+// UpdateSubscription godoc
+// @Summary      Update a webhook subscription
+// @Description  Replaces the settings of a subscription. An empty secret keeps the current one; a new secret is rotated with a grace period, and removeSecret stops signing.
+// @Tags         Webhook
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        instance  path      string                                 true  "Instance ID"
+// @Param        id        path      string                                 true  "Subscription ID"
+// @Param        body      body      dto.UpdateWebhookSubscriptionRequest  true  "Subscription"
+// @Success      200       {object}  dto.WebhookSubscriptionResponse
+// @Failure      400       {object}  utils.HTTPErrorResponse
+// @Failure      404       {object}  utils.HTTPErrorResponse
+// @Failure      409       {object}  utils.HTTPErrorResponse
+// @Failure      422       {object}  utils.HTTPErrorResponse
+// @Failure      500       {object}  utils.HTTPErrorResponse
+// @Router       /webhook/subscriptions/{instance}/{id} [put]
+func (s *Webhook) UpdateSubscription(ctx echo.Context) error {
+	var request dto.UpdateWebhookSubscriptionRequest
+	if err := ctx.Bind(&request); err != nil {
+		return utils.HTTPFail(ctx, http.StatusUnprocessableEntity, err, "failed to bind request body")
+	}
+
+	if err := s.validate.Struct(&request); err != nil {
+		return utils.HTTPFail(ctx, http.StatusBadRequest, err, "invalid request body")
+	}
+
+	c := ctx.Request().Context()
+	instance, err := s.loadInstance(c, request.InstanceID)
+	if err != nil {
+		return s.failInstance(ctx, err)
+	}
+
+	preview, err := previewSubscriptionTemplate(instance, request.WebhookSubscriptionData)
+	if err != nil {
+		return utils.HTTPFail(ctx, http.StatusBadRequest, err, "invalid webhook template")
+	}
+
+	if err := whatsmiau.CheckRetryPolicy(request.Retry); err != nil {
+		return utils.HTTPFail(ctx, http.StatusBadRequest, err, "invalid webhook retry policy")
+	}
+
+	gracePeriod := env.Env.WebhookSecretGracePeriod
+	if request.SecretGracePeriod > 0 {
+		gracePeriod = time.Duration(request.SecretGracePeriod) * time.Second
+	}
+	previousSecretExpiresAt := time.Now().Add(gracePeriod)
+
+	if err := subscriptionFromRequest(request.WebhookSubscriptionData).Validate(); err != nil {
+		return utils.HTTPFail(ctx, http.StatusBadRequest, err, "invalid webhook subscription")
+	}
+
+	var subscription models.WebhookSubscription
+	if _, err := s.repo.UpdateSubscriptions(c, request.InstanceID, func(instance *models.Instance) ([]models.WebhookSubscription, error) {
+		for i, old := range instance.Subscriptions {
+			if old.ID != request.ID {
+				continue
+			}
+
+			subscription = subscriptionFromRequest(request.WebhookSubscriptionData)
+			subscription.ID = old.ID
+			subscription.Auth.KeepMasked(old.Auth)
+			subscription.Secret = old.Secret
+			subscription.PreviousSecret = old.PreviousSecret
+			subscription.PreviousSecretExpiresAt = old.PreviousSecretExpiresAt
+			if request.RemoveSecret {
+				subscription.ClearSecret()
+			} else {
+				subscription.RotateSecret(request.Secret, &previousSecretExpiresAt)
+			}
+
+			instance.Subscriptions[i] = subscription
+			return instance.Subscriptions, nil
+		}
+
+		return nil, errSubscriptionNotFound
+	}); err != nil {
+		return s.failInstance(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusOK, dto.WebhookSubscriptionResponse{
+		Subscription:    subscriptionConfig(subscription),
+		TemplatePreview: preview,
+	})
+}
+
+// DeleteSubscription godoc
+// @Summary      Delete a webhook subscription
+// @Tags         Webhook
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        instance  path      string  true  "Instance ID"
+// @Param        id        path      string  true  "Subscription ID"
+// @Success      200       {object}  dto.WebhookSubscriptionResponse
+// @Failure      400       {object}  utils.HTTPErrorResponse
+// @Failure      404       {object}  utils.HTTPErrorResponse
+// @Failure      409       {object}  utils.HTTPErrorResponse
+// @Failure      422       {object}  utils.HTTPErrorResponse
+// @Failure      500       {object}  utils.HTTPErrorResponse
+// @Router       /webhook/subscriptions/{instance}/{id} [delete]
+func (s *Webhook) DeleteSubscription(ctx echo.Context) error {
+	var request dto.WebhookSubscriptionRequest
+	if err := ctx.Bind(&request); err != nil {
+		return utils.HTTPFail(ctx, http.StatusUnprocessableEntity, err, "failed to bind request body")
+	}
+
+	if err := s.validate.Struct(&request); err != nil {
+		return utils.HTTPFail(ctx, http.StatusBadRequest, err, "invalid request body")
+	}
+
+	var subscription models.WebhookSubscription
+	if _, err := s.repo.UpdateSubscriptions(ctx.Request().Context(), request.InstanceID, func(instance *models.Instance) ([]models.WebhookSubscription, error) {
+		for i, old := range instance.Subscriptions {
+			if old.ID == request.ID {
+				subscription = old
+				return append(instance.Subscriptions[:i:i], instance.Subscriptions[i+1:]...), nil
+			}
+		}
+
+		return nil, errSubscriptionNotFound
+	}); err != nil {
+		return s.failInstance(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusOK, dto.WebhookSubscriptionResponse{
+		Subscription: subscriptionConfig(subscription),
+	})
+}
+
+func subscriptionFromRequest(data dto.WebhookSubscriptionData) models.WebhookSubscription {
+	var template *string
+	if data.Template != "" {
+		template = &data.Template
+	}
+
+	return models.WebhookSubscription{
+		InstanceWebhook: models.InstanceWebhook{
+			Enabled:   data.Enabled,
+			Url:       data.URL,
+			ByEvents:  data.ByEvents,
+			Base64:    data.Base64,
+			Headers:   data.Headers,
+			Events:    data.Events,
+			EventUrls: data.EventUrls,
+			Auth:      data.Auth,
+			RateLimit: data.RateLimit,
+			Ordered:   data.Ordered,
+			Retry:     data.Retry,
+			Batch:     data.Batch,
+			Gzip:      data.Gzip,
+			Template:  template,
+		},
+		IncludeJids: data.IncludeJids,
+		ExcludeJids: data.ExcludeJids,
+		FromMe:      data.FromMe,
+	}
+}
+
+func subscriptionConfig(subscription models.WebhookSubscription) *dto.WebhookSubscriptionConfig {
+	return &dto.WebhookSubscriptionConfig{
+		WebhookSubscription: subscription.Redacted(),
+		HasSecret:           subscription.Secret != "",
+	}
+}
+
+// previewSubscriptionTemplate validates the template of a subscription by
+// rendering it for a sample event; it is nil without a template.
+func previewSubscriptionTemplate(instance *models.Instance, data dto.WebhookSubscriptionData) (json.RawMessage, error) {
+	if data.Template == "" {
+		return nil, nil
+	}
+
+	return whatsmiau.PreviewWebhookTemplate(instance, data.Template, whatsmiau.WookMessagesUpsert)
+}
+
+func (s *Webhook) loadInstance(ctx context.Context, id string) (*models.Instance, error) {
+	result, err := s.repo.List(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(result) == 0 {
+		return nil, instances.ErrorNotFound
+	}
+
+	return &result[0], nil
+}
+
+func (s *Webhook) failInstance(ctx echo.Context, err error) error {
+	if errors.Is(err, instances.ErrorNotFound) {
+		return utils.HTTPFail(ctx, http.StatusNotFound, err, "instance not found")
+	}
+	if errors.Is(err, errSubscriptionNotFound) {
+		return utils.HTTPFail(ctx, http.StatusNotFound, err, "subscription not found")
+	}
+	if errors.Is(err, instances.ErrConcurrentUpdate) {
+		return utils.HTTPFail(ctx, http.StatusConflict, err, "instance was updated concurrently")
+	}
+
+	zap.L().Error("failed to update webhook subscriptions", zap.Error(err))
+	return utils.HTTPFail(ctx, http.StatusInternalServerError, err, "failed to update webhook subscriptions")
+}
@@ -37,6 +37,11 @@ type SendChatPresenceResponse struct {
 	Presence SendPresenceRequestPresence `json:"presence"`
 }
 
+type SubscribePresenceRequest struct {
+	InstanceID string `param:"instance" validate:"required" swaggerignore:"true"`
+	Number     string `json:"number" validate:"required"`
+}
+
 type NumberExistsRequest struct {
 	Numbers []string `json:"numbers"     validate:"required,min=1,dive,required"`
 }
@@ -0,0 +1,20 @@
+package dto
+
+type StreamEventsRequest struct {
+	InstanceID string `param:"instance"`
+	// Events is a comma separated filter, e.g. MESSAGES_UPSERT,CONNECTION_UPDATE
+	Events string `query:"events"`
+	Base64 bool   `query:"base64"`
+}
+
+// StreamEventsSubscribe is sent by the client over an open stream to replace
+// its event filter; an empty list subscribes to every event.
+type StreamEventsSubscribe struct {
+	Events []string `json:"events"`
+}
+
+// StreamEventsNotice is sent to the client when a frame it sent is invalid, and
+// right before the server closes the stream.
+type StreamEventsNotice struct {
+	Error string `json:"error"`
+}
@@ -1,5 +1,7 @@
 package dto
 
+import "github.com/verbeux-ai/whatsmiau/models"
+
 // --- sendMedia (extended to support video) ---
 // SendMediaRequest already exists in message.go and accepts mediatype.
 // Mediatype values: "image" | "document" | "video"
@@ -139,3 +141,39 @@ type SendStatusResponse struct {
 	MessageTimestamp int                `json:"messageTimestamp"`
 	InstanceId       string             `json:"instanceId"`
 }
+
+// --- updateMessage ---
+
+type UpdateMessageRequest struct {
+	InstanceID string           `param:"instance" swaggerignore:"true"`
+	Number     string           `json:"number,omitempty" validate:"required"`
+	Key        UpdateMessageKey `json:"key"`
+	Text       string           `json:"text,omitempty" validate:"required"`
+	// Type is the kind of the original message, whose caption is edited for
+	// image, video and document. Defaults to text.
+	Type string `json:"type,omitempty" validate:"omitempty,oneof=text image video document"`
+}
+
+type UpdateMessageKey struct {
+	Id string `json:"id,omitempty" validate:"required"`
+}
+
+type UpdateMessageResponse struct {
+	Key              MessageResponseKey `json:"key"` // of the edited message
+	EditId           string             `json:"editId"`
+	Status           string             `json:"status"`
+	MessageType      string             `json:"messageType"`
+	MessageTimestamp int                `json:"messageTimestamp"`
+	InstanceId       string             `json:"instanceId"`
+}
+
+// --- pollResults ---
+
+type PollResultsRequest struct {
+	InstanceID string `param:"instance" swaggerignore:"true"`
+	ID         string `param:"id" validate:"required"`
+}
+
+type PollResultsResponse struct {
+	*models.PollResults
+}
@@ -0,0 +1,24 @@
+package dto
+
+import "github.com/verbeux-ai/whatsmiau/models"
+
+// SetSettingsRequest only changes the settings present in the body.
+type SetSettingsRequest struct {
+	InstanceID string `param:"instance" validate:"required" swaggerignore:"true"`
+	models.InstanceSettings
+}
+
+type FindSettingsRequest struct {
+	InstanceID string `param:"instance" validate:"required" swaggerignore:"true"`
+}
+
+type SettingsResponse struct {
+	RejectCall        bool   `json:"rejectCall"`
+	MsgCall           string `json:"msgCall"`
+	GroupsIgnore      bool   `json:"groupsIgnore"`
+	AlwaysOnline      bool   `json:"alwaysOnline"`
+	ReadMessages      bool   `json:"readMessages"`
+	ReadStatus        bool   `json:"readStatus"`
+	SyncFullHistory   bool   `json:"syncFullHistory"`
+	SyncRecentHistory bool   `json:"syncRecentHistory"`
+}
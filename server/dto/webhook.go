@@ -1,6 +1,11 @@
 package dto
 
-import "github.com/verbeux-ai/whatsmiau/models"
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/verbeux-ai/whatsmiau/models"
+)
 
 type SetWebhookRequest struct {
 	InstanceID string                `param:"instance" validate:"required"`
@@ -14,10 +19,43 @@ type SetWebhookRequestData struct {
 	ByEvents *bool             `json:"byEvents,omitempty"`
 	Base64   *bool             `json:"base64,omitempty"`
 	Events   []string          `json:"events,omitempty"`
+	// EventUrls overrides the destination of single events, keyed like Events.
+	// An empty value removes the override.
+	EventUrls map[string]string `json:"eventUrls,omitempty"`
+	// Secret enables HMAC-SHA256 signed deliveries. Sending a different value
+	// rotates it; the previous secret keeps signing for SecretGracePeriod seconds.
+	Secret            string `json:"secret,omitempty"`
+	SecretGracePeriod int    `json:"secretGracePeriod,omitempty" validate:"omitempty,min=0"`
+	// RemoveSecret stops signing deliveries.
+	RemoveSecret bool `json:"removeSecret,omitempty" validate:"excluded_with=Secret"`
+	// Auth replaces the authentication block; send {"type": ""} to remove it.
+	Auth *models.InstanceWebhookAuth `json:"auth,omitempty"`
+	// RateLimit caps deliveries per second per URL; a negative value goes back
+	// to WEBHOOK_RATE_LIMIT.
+	RateLimit float64 `json:"rateLimit,omitempty"`
+	// Ordered delivers the events of each chat in sequence; omit it to follow
+	// WEBHOOK_ORDERED.
+	Ordered *bool `json:"ordered,omitempty"`
+	// Retry replaces the retry policy; send {} to go back to the defaults.
+	Retry *models.WebhookRetryPolicy `json:"retry,omitempty"`
+	// Batch enables batched delivery; send {"size": 0} to disable it.
+	Batch *models.WebhookBatch `json:"batch,omitempty"`
+	Gzip  *bool                `json:"gzip,omitempty"`
+	// Template maps the events into a custom body; send "" to remove it.
+	Template *string `json:"template,omitempty" validate:"omitempty,max=65536"`
+}
+
+// WebhookConfig is a webhook as the API returns it. Its secrets are never
+// returned; HasSecret tells whether deliveries are signed.
+type WebhookConfig struct {
+	models.InstanceWebhook
+	HasSecret bool `json:"hasSecret"`
 }
 
 type SetWebhookResponse struct {
-	Webhook *models.InstanceWebhook `json:"webhook"`
+	Webhook *WebhookConfig `json:"webhook"`
+	// TemplatePreview is the template rendered for a sample MESSAGES_UPSERT.
+	TemplatePreview json.RawMessage `json:"templatePreview,omitempty"`
 }
 
 type FindWebhookRequest struct {
@@ -25,5 +63,165 @@ type FindWebhookRequest struct {
 }
 
 type FindWebhookResponse struct {
-	Webhook *models.InstanceWebhook `json:"webhook"`
+	Webhook *WebhookConfig `json:"webhook"`
+}
+
+type ListDeadLettersRequest struct {
+	InstanceID string    `param:"instance" validate:"required" swaggerignore:"true"`
+	From       time.Time `query:"from"`
+	To         time.Time `query:"to"`
+	Limit      int64     `query:"limit" validate:"omitempty,min=1"`
+}
+
+type ListDeadLettersResponse struct {
+	DeadLetters []models.DeadLetter `json:"deadLetters"`
+}
+
+type ReplayDeadLetterRequest struct {
+	InstanceID string `param:"instance" validate:"required" swaggerignore:"true"`
+	ID         string `param:"id" validate:"required" swaggerignore:"true"`
+}
+
+type ReplayDeadLettersRequest struct {
+	InstanceID string    `param:"instance" validate:"required" swaggerignore:"true"`
+	From       time.Time `json:"from,omitempty"`
+	To         time.Time `json:"to,omitempty"`
+}
+
+type ReplayDeadLettersResponse struct {
+	Replayed int `json:"replayed"`
+}
+
+type PurgeDeadLettersRequest struct {
+	InstanceID string    `param:"instance" validate:"required" swaggerignore:"true"`
+	From       time.Time `query:"from"`
+	To         time.Time `query:"to"`
+}
+
+type PurgeDeadLettersResponse struct {
+	Purged int64 `json:"purged"`
+}
+
+type TestWebhookRequest struct {
+	InstanceID string `param:"instance" validate:"required" swaggerignore:"true"`
+	// Event is one of MESSAGES_UPSERT, MESSAGES_UPDATE, MESSAGES_DELETE,
+	// CONTACTS_UPSERT, CONNECTION_UPDATE or CALL.
+	Event string `json:"event" validate:"required"`
+	// Subscription targets a subscription, or the global webhook with "global",
+	// instead of the main webhook.
+	Subscription string `json:"subscription,omitempty"`
+}
+
+type TestWebhookResponse struct {
+	Result *models.WebhookTestResult `json:"result"`
+}
+
+type PreviewWebhookTemplateRequest struct {
+	InstanceID string `param:"instance" validate:"required" swaggerignore:"true"`
+	Template   string `json:"template" validate:"required,max=65536"`
+	// Event is the sample event rendered, MESSAGES_UPSERT by default. The
+	// events of the test-fire endpoint are supported.
+	Event string `json:"event,omitempty"`
+}
+
+type PreviewWebhookTemplateResponse struct {
+	Preview json.RawMessage `json:"preview"`
+}
+
+type ListWebhookDeliveriesRequest struct {
+	InstanceID   string    `param:"instance" validate:"required" swaggerignore:"true"`
+	Event        string    `query:"event"`
+	Subscription string    `query:"subscription"`
+	MessageID    string    `query:"messageId"`
+	Status       string    `query:"status" validate:"omitempty,oneof=success failure"`
+	StatusCode   int       `query:"statusCode" validate:"omitempty,min=100,max=599"`
+	From         time.Time `query:"from"`
+	To           time.Time `query:"to"`
+	Limit        int       `query:"limit" validate:"omitempty,min=1"`
+}
+
+type ListWebhookDeliveriesResponse struct {
+	Deliveries []models.WebhookDelivery `json:"deliveries"`
+}
+
+type WebhookDeliveryStatsRequest struct {
+	InstanceID string `param:"instance" validate:"required" swaggerignore:"true"`
+}
+
+type WebhookDeliveryStatsResponse struct {
+	Stats       *models.WebhookDeliveryStats `json:"stats"`
+	SuccessRate float64                      `json:"successRate"` // percentage
+}
+
+type WebhookSubscriptionData struct {
+	Enabled           *bool                       `json:"enabled,omitempty"`
+	URL               string                      `json:"url" validate:"required,url"`
+	ByEvents          *bool                       `json:"byEvents,omitempty"`
+	Base64            *bool                       `json:"base64,omitempty"`
+	Headers           map[string]string           `json:"headers,omitempty"`
+	Events            []string                    `json:"events" validate:"required,min=1"`
+	EventUrls         map[string]string           `json:"eventUrls,omitempty"`
+	Secret            string                      `json:"secret,omitempty"`
+	SecretGracePeriod int                         `json:"secretGracePeriod,omitempty" validate:"omitempty,min=0"`
+	RemoveSecret      bool                        `json:"removeSecret,omitempty" validate:"excluded_with=Secret"`
+	Auth              *models.InstanceWebhookAuth `json:"auth,omitempty"`
+	IncludeJids       []string                    `json:"includeJids,omitempty"`
+	ExcludeJids       []string                    `json:"excludeJids,omitempty"`
+	FromMe            *bool                       `json:"fromMe,omitempty"`
+	RateLimit         float64                     `json:"rateLimit,omitempty" validate:"omitempty,min=0"`
+	Ordered           *bool                       `json:"ordered,omitempty"`
+	Retry             *models.WebhookRetryPolicy  `json:"retry,omitempty"`
+	Batch             *models.WebhookBatch        `json:"batch,omitempty"`
+	Gzip              *bool                       `json:"gzip,omitempty"`
+	Template          string                      `json:"template,omitempty" validate:"max=65536"`
+}
+
+type CreateWebhookSubscriptionRequest struct {
+	InstanceID string `param:"instance" validate:"required" swaggerignore:"true"`
+	WebhookSubscriptionData
+}
+
+type UpdateWebhookSubscriptionRequest struct {
+	InstanceID string `param:"instance" validate:"required" swaggerignore:"true"`
+	ID         string `param:"id" validate:"required" swaggerignore:"true"`
+	WebhookSubscriptionData
+}
+
+type WebhookSubscriptionRequest struct {
+	InstanceID string `param:"instance" validate:"required" swaggerignore:"true"`
+	ID         string `param:"id" validate:"required" swaggerignore:"true"`
+}
+
+// WebhookSubscriptionConfig is a subscription as the API returns it, like
+// WebhookConfig.
+type WebhookSubscriptionConfig struct {
+	models.WebhookSubscription
+	HasSecret bool `json:"hasSecret"`
+}
+
+type WebhookSubscriptionResponse struct {
+	Subscription    *WebhookSubscriptionConfig `json:"subscription"`
+	TemplatePreview json.RawMessage            `json:"templatePreview,omitempty"`
+}
+
+type ListWebhookSubscriptionsRequest struct {
+	InstanceID string `param:"instance" validate:"required" swaggerignore:"true"`
+}
+
+type ListWebhookSubscriptionsResponse struct {
+	Subscriptions []WebhookSubscriptionConfig `json:"subscriptions"`
+}
+
+type SetSinkRequest struct {
+	InstanceID string `param:"instance" validate:"required" swaggerignore:"true"`
+	// Sink replaces the broker sink; send {"type": ""} to remove it.
+	Sink models.InstanceSink `json:"sink"`
+}
+
+type SinkResponse struct {
+	Sink *models.InstanceSink `json:"sink"`
+}
+
+type FindSinkRequest struct {
+	InstanceID string `param:"instance" validate:"required" swaggerignore:"true"`
 }
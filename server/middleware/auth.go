@@ -14,6 +14,10 @@ func Auth(ctx echo.Context, next echo.HandlerFunc) error {
 	}
 
 	gotApikey := ctx.Request().Header.Get("apikey")
+	if gotApikey == "" && ctx.IsWebSocket() {
+		// browsers cannot set headers on the websocket handshake
+		gotApikey = ctx.QueryParam("apikey")
+	}
 	if len(env.Env.ApiKey) == 0 {
 		return next(ctx)
 	}
@@ -13,6 +13,7 @@ func Chat(group *echo.Group) {
 	controller := controllers.NewChats(redisInstance, whatsmiau.Get())
 
 	group.POST("/presence", controller.SendChatPresence)
+	group.POST("/subscribe-presence", controller.SubscribePresence)
 	group.POST("/read-messages", controller.ReadMessages)
 	group.DELETE("/deleteMessageForEveryone", controller.DeleteMessageForEveryone)
 }
@@ -24,6 +25,7 @@ func ChatEVO(group *echo.Group) {
 	// Evolution API Compatibility (partially REST)
 	group.POST("/markMessageAsRead/:instance", controller.ReadMessages)
 	group.POST("/sendPresence/:instance", controller.SendChatPresence)
+	group.POST("/subscribePresence/:instance", controller.SubscribePresence)
 	group.POST("/whatsappNumbers/:instance", controller.NumberExists)
 	group.DELETE("/deleteMessageForEveryone/:instance", controller.DeleteMessageForEveryone)
 }
@@ -35,4 +35,6 @@ func V1(group *echo.Group) {
 	MessageEVO(group.Group("/message"))
 	GroupEVO(group.Group("/group"))
 	Webhook(group.Group("/webhook"))
+	Settings(group.Group("/settings"))
+	Events(group)
 }
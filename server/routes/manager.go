@@ -9,6 +9,7 @@ import (
 	echomw "github.com/labstack/echo/v4/middleware"
 	"github.com/verbeux-ai/whatsmiau/env"
 	"github.com/verbeux-ai/whatsmiau/lib/whatsmiau"
+	"github.com/verbeux-ai/whatsmiau/repositories/deliveries"
 	"github.com/verbeux-ai/whatsmiau/repositories/instances"
 	"github.com/verbeux-ai/whatsmiau/server/controllers"
 	"github.com/verbeux-ai/whatsmiau/server/middleware"
@@ -28,7 +29,9 @@ func Manager(group *echo.Group) {
 	w := whatsmiau.Get()
 	tmpl := controllers.ParseManagerTemplates()
 
-	controller := controllers.NewManager(repo, w, tmpl)
+	deliveryRepo := deliveries.NewRedis(services.Redis(), env.Env.WebhookDeliveryLogSize)
+
+	controller := controllers.NewManager(repo, deliveryRepo, w, tmpl)
 
 	group.Use(echomw.CORSWithConfig(echomw.CORSConfig{
 		AllowOrigins: []string{managerOrigin()},
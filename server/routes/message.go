@@ -24,6 +24,8 @@ func Message(group *echo.Group) {
 	group.POST("/status", controller.SendStatus)
 	group.POST("/list", controller.SendList)
 	group.POST("/buttons", controller.SendButtons)
+	group.POST("/update", controller.UpdateMessage)
+	group.GET("/poll/:id", controller.PollResults)
 }
 
 func MessageEVO(group *echo.Group) {
@@ -43,4 +45,6 @@ func MessageEVO(group *echo.Group) {
 	group.POST("/sendReaction/:instance", controller.SendReaction)
 	group.POST("/sendList/:instance", controller.SendList)
 	group.POST("/sendButtons/:instance", controller.SendButtons)
+	group.POST("/updateMessage/:instance", controller.UpdateMessage)
+	group.GET("/pollResults/:instance/:id", controller.PollResults)
 }
@@ -2,7 +2,10 @@ package routes
 
 import (
 	"github.com/labstack/echo/v4"
+	"github.com/verbeux-ai/whatsmiau/env"
 	"github.com/verbeux-ai/whatsmiau/lib/whatsmiau"
+	"github.com/verbeux-ai/whatsmiau/repositories/deadletters"
+	"github.com/verbeux-ai/whatsmiau/repositories/deliveries"
 	"github.com/verbeux-ai/whatsmiau/repositories/instances"
 	"github.com/verbeux-ai/whatsmiau/server/controllers"
 	"github.com/verbeux-ai/whatsmiau/services"
@@ -10,8 +13,30 @@ import (
 
 func Webhook(group *echo.Group) {
 	redisInstance := instances.NewRedis(services.Redis())
-	controller := controllers.NewWebhooks(redisInstance, whatsmiau.Get())
+	redisDeadLetter := deadletters.NewRedis(services.Redis(), env.Env.WebhookDeadLetterMaxLen)
+	redisDelivery := deliveries.NewRedis(services.Redis(), env.Env.WebhookDeliveryLogSize)
+	controller := controllers.NewWebhooks(redisInstance, redisDeadLetter, redisDelivery, whatsmiau.Get())
 
 	group.POST("/set/:instance", controller.Set)
 	group.GET("/find/:instance", controller.Find)
+	group.POST("/sink/:instance", controller.SetSink)
+	group.GET("/sink/:instance", controller.FindSink)
+
+	group.GET("/dead-letters/:instance", controller.ListDeadLetters)
+	group.DELETE("/dead-letters/:instance", controller.PurgeDeadLetters)
+	group.POST("/dead-letters/:instance/replay", controller.ReplayDeadLetters)
+	group.POST("/dead-letters/:instance/replay/:id", controller.ReplayDeadLetter)
+
+	group.POST("/test/:instance", controller.Test)
+	group.POST("/template/preview/:instance", controller.PreviewTemplate)
+
+	group.GET("/deliveries/:instance", controller.ListDeliveries)
+	group.GET("/deliveries/:instance/stats", controller.DeliveryStats)
+	group.DELETE("/deliveries/:instance", controller.ResetDeliveries)
+
+	group.GET("/subscriptions/:instance", controller.ListSubscriptions)
+	group.POST("/subscriptions/:instance", controller.CreateSubscription)
+	group.GET("/subscriptions/:instance/:id", controller.GetSubscription)
+	group.PUT("/subscriptions/:instance/:id", controller.UpdateSubscription)
+	group.DELETE("/subscriptions/:instance/:id", controller.DeleteSubscription)
 }